@@ -2,25 +2,67 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
+const (
+	defaultMaxRetries   = 4
+	defaultMaxRetryWait = time.Second * 30
+)
+
 type Client struct {
 	baseURL    *url.URL
 	UserAgent  string
 	token      string
 	httpClient *http.Client
+
+	// MaxRetries is the number of times a failed request is retried
+	MaxRetries int
+	// MaxRetryWait caps the time spent waiting between two attempts
+	MaxRetryWait time.Duration
 }
 
-func New(baseURLString, token string) *Client {
-	baseURL, err := url.Parse(baseURLString)
+// Option configures optional Client settings
+type Option func(*Client) error
+
+// WithRetries sets how many times a request is retried and the
+// longest wait between two attempts
+func WithRetries(maxRetries int, maxRetryWait time.Duration) Option {
+	return func(c *Client) error {
+		c.MaxRetries = maxRetries
+		c.MaxRetryWait = maxRetryWait
+		return nil
+	}
+}
+
+// WithBaseURL overrides the base URL given to New, an empty
+// string keeps the default
+func WithBaseURL(baseURLString string) Option {
+	return func(c *Client) error {
+		if baseURLString == "" {
+			return nil
+		}
+		baseURL, err := parseBaseURL(baseURLString)
+		if err != nil {
+			return err
+		}
+		c.baseURL = baseURL
+		return nil
+	}
+}
+
+func New(baseURLString, token string, opts ...Option) (*Client, error) {
+	baseURL, err := parseBaseURL(baseURLString)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	client := &http.Client{
@@ -28,16 +70,45 @@ func New(baseURLString, token string) *Client {
 		Transport: http.DefaultTransport,
 	}
 
-	return &Client{
-		baseURL:    baseURL,
-		httpClient: client,
-		token:      token,
+	c := &Client{
+		baseURL:      baseURL,
+		httpClient:   client,
+		token:        token,
+		MaxRetries:   defaultMaxRetries,
+		MaxRetryWait: defaultMaxRetryWait,
 	}
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func parseBaseURL(baseURLString string) (*url.URL, error) {
+	baseURL, err := url.Parse(baseURLString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", baseURLString, err)
+	}
+	if baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme and host are required", baseURLString)
+	}
+	return baseURL, nil
 }
 
 func (c *Client) NewRequest(method, path string, body interface{}) (*http.Request, error) {
-	rel := &url.URL{Path: path}
-	u := c.baseURL.ResolveReference(rel)
+	return c.NewRequestWithContext(context.Background(), method, path, body)
+}
+
+// NewRequestWithContext is like NewRequest but the returned request is
+// bound to ctx, cancelling ctx aborts the request and any pending retries
+func (c *Client) NewRequestWithContext(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	// Paths are appended to the base URL path rather than resolved against
+	// it, so endpoints behind a proxy prefix keep working
+	u := *c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
 
 	var buf io.ReadWriter
 	if body != nil {
@@ -50,7 +121,7 @@ func (c *Client) NewRequest(method, path string, body interface{}) (*http.Reques
 		}
 	}
 
-	req, err := http.NewRequest(method, u.String(), buf)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), buf)
 	if err != nil {
 		return nil, err
 	}
@@ -58,7 +129,9 @@ func (c *Client) NewRequest(method, path string, body interface{}) (*http.Reques
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	req.Header.Set("Authorization", c.getToken())
+	if c.token != "" {
+		req.Header.Set("Authorization", c.getToken())
+	}
 	req.Header.Set("User-Agent", c.UserAgent)
 	return req, nil
 }
@@ -67,18 +140,19 @@ func (c *Client) getToken() string {
 	return "Bearer " + c.token
 }
 
+// Do sends the request and decodes the JSON response body into v.
+// Rate limited requests, server errors and transport errors are retried
+// with exponential backoff, see retry.go for the exact policy.
+// Non-2xx responses are returned as *APIError along with the response.
+// The request context is honoured while sending and between retries.
 func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
-	resp, err := c.httpClient.Do(req)
+	resp, b, err := c.doWithRetry(req)
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-
-	b, err := ioutil.ReadAll(resp.Body)
-
-	if err != nil {
-		return nil, err
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, apiErrorFromBody(resp.StatusCode, b)
 	}
 
 	if v != nil {
@@ -89,3 +163,46 @@ func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
 	}
 	return resp, err
 }
+
+func (c *Client) doWithRetry(req *http.Request) (*http.Response, []byte, error) {
+	retryable := isRetryableRequest(req)
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, nil, err
+			}
+			req.Body = body
+		}
+
+		resp, b, err := c.send(req)
+
+		if attempt >= c.MaxRetries || !shouldRetry(resp, err, retryable) {
+			return resp, b, err
+		}
+
+		select {
+		case <-time.After(c.backoff(attempt, resp)):
+		case <-req.Context().Done():
+			return nil, nil, req.Context().Err()
+		}
+	}
+}
+
+func (c *Client) send(req *http.Request) (*http.Response, []byte, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, b, nil
+}
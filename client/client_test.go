@@ -0,0 +1,143 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client/common"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+)
+
+func newTestClient(t *testing.T, srv *fakespotinst.Server) *client.Client {
+	c, err := client.New(srv.URL, srv.APIToken, client.WithRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNewInvalidBaseURL(t *testing.T) {
+	if _, err := client.New("not a url", "token"); err == nil {
+		t.Fatal("expected an error for an invalid base URL")
+	}
+}
+
+func TestDoRetriesRateLimitedRequests(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	srv.InjectFault(fakespotinst.Fault{Path: "/setup/account", StatusCode: http.StatusTooManyRequests, RetryAfter: "0", Times: 2})
+
+	c := newTestClient(t, srv)
+	req, err := c.NewRequest(http.MethodPost, "/setup/account", map[string]map[string]string{"account": {"name": "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r common.Response
+	if _, err := c.Do(req, &r); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.RequestCount(http.MethodPost, "/setup/account"); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+	if n := len(srv.Accounts()); n != 1 {
+		t.Fatalf("expected the account to be created once, got %d", n)
+	}
+}
+
+func TestDoDoesNotRetryUnsafePost(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	srv.InjectFault(fakespotinst.Fault{Path: "/setup/account", StatusCode: http.StatusInternalServerError})
+
+	c := newTestClient(t, srv)
+	req, _ := c.NewRequest(http.MethodPost, "/setup/account", map[string]map[string]string{"account": {"name": "a"}})
+
+	_, err := c.Do(req, nil)
+
+	apiErr, ok := err.(*client.APIError)
+	if !ok {
+		t.Fatalf("expected *client.APIError, got %#v", err)
+	}
+	if apiErr.StatusCode != http.StatusInternalServerError || apiErr.RequestID == "" || len(apiErr.Errors) != 1 {
+		t.Fatalf("unexpected error %#v", apiErr)
+	}
+	if got := srv.RequestCount(http.MethodPost, "/setup/account"); got != 1 {
+		t.Fatalf("expected a single attempt, got %d", got)
+	}
+}
+
+func TestDoRetriesIdempotentPost(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	srv.InjectFault(fakespotinst.Fault{Path: "/auth/signIn", StatusCode: http.StatusBadGateway, Times: 2})
+
+	c := newTestClient(t, srv)
+	req, _ := c.NewRequest(http.MethodPost, "/auth/signIn", map[string]string{"email": srv.Email, "password": srv.Password})
+
+	if _, err := c.Do(client.Idempotent(req), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.RequestCount(http.MethodPost, "/auth/signIn"); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestDoGivesUpAfterMaxRetries(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	srv.InjectFault(fakespotinst.Fault{Path: "/setup/account", StatusCode: http.StatusTooManyRequests, RetryAfter: "0"})
+
+	c := newTestClient(t, srv)
+	req, _ := c.NewRequest(http.MethodGet, "/setup/account", nil)
+
+	_, err := c.Do(req, nil)
+	if !client.IsRateLimited(err) {
+		t.Fatalf("expected a rate limited error, got %v", err)
+	}
+	if got := srv.RequestCount(http.MethodGet, "/setup/account"); got != 4 {
+		t.Fatalf("expected 4 attempts, got %d", got)
+	}
+}
+
+func TestDoHonoursContext(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	srv.InjectFault(fakespotinst.Fault{Path: "/setup/account", Latency: time.Minute})
+
+	c := newTestClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, _ := c.NewRequestWithContext(ctx, http.MethodGet, "/setup/account", nil)
+
+	if _, err := c.Do(req, nil); err == nil {
+		t.Fatal("expected the request to be cancelled")
+	}
+}
+
+func TestErrorHelpers(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	c := newTestClient(t, srv)
+
+	req, _ := c.NewRequest(http.MethodDelete, "/setup/account/act-missing", nil)
+	if _, err := c.Do(req, nil); !client.IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+
+	unauthorized, _ := client.New(srv.URL, "wrong-token")
+	req, _ = unauthorized.NewRequest(http.MethodGet, "/setup/account", nil)
+	if _, err := unauthorized.Do(req, nil); !client.IsUnauthorized(err) {
+		t.Fatalf("expected an unauthorized error, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client/common"
+)
+
+// APIError is returned by Do for every non-2xx response
+type APIError struct {
+	StatusCode int
+	RequestID  string
+	Errors     []common.ResponseError
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "spotinst API returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request id %s)", e.RequestID)
+	}
+
+	for i, re := range e.Errors {
+		if i == 0 {
+			b.WriteString(":")
+		} else {
+			b.WriteString(";")
+		}
+		fmt.Fprintf(&b, " %s", re.Code)
+		if re.Message != "" {
+			fmt.Fprintf(&b, " %s", re.Message)
+		}
+		if re.Field != "" {
+			fmt.Fprintf(&b, " (field %s)", re.Field)
+		}
+	}
+
+	return b.String()
+}
+
+// NewAPIError builds an APIError out of a decoded response envelope
+func NewAPIError(statusCode int, r *common.Response) *APIError {
+	return &APIError{
+		StatusCode: statusCode,
+		RequestID:  r.Request.ID,
+		Errors:     r.Response.Errors,
+	}
+}
+
+func apiErrorFromBody(statusCode int, body []byte) *APIError {
+	var r common.Response
+
+	// Not every error body is a Spotinst envelope, the status code
+	// alone is still worth reporting then
+	if err := json.Unmarshal(body, &r); err != nil {
+		return &APIError{StatusCode: statusCode}
+	}
+
+	return NewAPIError(statusCode, &r)
+}
+
+// IsNotFound reports whether err carries an APIError with status 404
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether err carries an APIError with status 409
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// IsUnauthorized reports whether err carries an APIError with status 401 or 403
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized) || hasStatus(err, http.StatusForbidden)
+}
+
+// IsRateLimited reports whether err carries an APIError with status 429
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+func hasStatus(err error, statusCode int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const baseRetryWait = time.Second
+
+type idempotentKey struct{}
+
+// Idempotent marks a request as safe to repeat. GET, HEAD, OPTIONS, PUT
+// and DELETE are retried on server and transport errors anyway, this is
+// meant for POST requests which have no side effects when sent twice.
+func Idempotent(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), idempotentKey{}, true))
+}
+
+func isRetryableRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	safe, _ := req.Context().Value(idempotentKey{}).(bool)
+	return safe
+}
+
+// shouldRetry decides whether an attempt is worth repeating. A 429 means
+// the API rejected the request before doing anything, so it is retried
+// for every method. Server and transport errors may happen after the
+// request was processed, so only idempotent requests are retried then.
+func shouldRetry(resp *http.Response, err error, retryable bool) bool {
+	if err != nil {
+		return retryable
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return retryable
+	}
+	return false
+}
+
+// backoff returns how long to wait before the next attempt. Retry-After
+// sent by the API wins, otherwise it is exponential backoff with full jitter.
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > c.MaxRetryWait {
+				return c.MaxRetryWait
+			}
+			return wait
+		}
+	}
+
+	ceiling := float64(baseRetryWait) * math.Pow(2, float64(attempt))
+	if ceiling > float64(c.MaxRetryWait) {
+		ceiling = float64(c.MaxRetryWait)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		wait := time.Until(at)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
@@ -4,24 +4,59 @@ const (
 	providerTokenAttrKey    = "token"
 	providerEmailAttrKey    = "email"
 	providerPasswordAttrKey = "password"
+
+	providerMaxRetriesAttrKey   = "max_retries"
+	providerMaxRetryWaitAttrKey = "max_retry_wait"
+
+	providerAccountsEndpointAttrKey = "accounts_endpoint"
+	providerUsersEndpointAttrKey    = "users_endpoint"
+	providerSignInEndpointAttrKey   = "sign_in_endpoint"
 )
 
 const (
 	envSpotinstTokenKey    = "SPOTINST_TOKEN"
 	envSpotinstEmailKey    = "SPOTINST_EMAIL"
 	envSpotinstPasswordKey = "SPOTINST_PASSWORD"
+
+	envSpotinstAccountsEndpointKey = "SPOTINST_ACCOUNTS_ENDPOINT"
+	envSpotinstUsersEndpointKey    = "SPOTINST_USERS_ENDPOINT"
+	envSpotinstSignInEndpointKey   = "SPOTINST_SIGN_IN_ENDPOINT"
 )
 
 const (
 	providerName                 = "spotinstadmin"
 	accountResourceName          = providerName + "_account"
 	programmaticUserResourceName = providerName + "_programmatic_user"
+	awsCredentialsResourceName   = providerName + "_aws_credentials"
+	awsExternalIDResourceName    = providerName + "_aws_external_id"
+
+	accountDataSourceName  = providerName + "_account"
+	accountsDataSourceName = providerName + "_accounts"
 )
 
 const (
 	accountResourceNameAttrKey       = "name"
 	accountResourceRoleArnAttrKey    = "aws_role_arn"
 	accountResourceExternalIDAttrKey = "aws_external_id"
+
+	accountResourceOrganizationIDAttrKey     = "organization_id"
+	accountResourceProviderExternalIDAttrKey = "provider_external_id"
+	accountResourceCloudProviderAttrKey      = "cloud_provider"
+	accountResourceCreatedAtAttrKey          = "created_at"
+
+	accountResourceAzureCredentialsAttrKey = "azure_credentials"
+	accountResourceGCPCredentialsAttrKey   = "gcp_credentials"
+)
+
+const (
+	azureCredentialsTenantIDAttrKey       = "tenant_id"
+	azureCredentialsClientIDAttrKey       = "client_id"
+	azureCredentialsClientSecretAttrKey   = "client_secret"
+	azureCredentialsSubscriptionIDAttrKey = "subscription_id"
+)
+
+const (
+	gcpCredentialsServiceAccountJSONAttrKey = "service_account_json"
 )
 
 const (
@@ -29,4 +64,31 @@ const (
 	userResourceNameAttrKey        = "name"
 	userResourceDescriptionAttrKey = "description"
 	userResourceAccessTokenAttrKey = "access_token"
+
+	userResourcePermissionStrategyAttrKey = "permission_strategy"
+	userResourceAccountRoleAttrKey        = "account_role"
+	userResourcePolicyIDsAttrKey          = "policy_ids"
+
+	userResourcePGPKeyAttrKey               = "pgp_key"
+	userResourceEncryptedAccessTokenAttrKey = "encrypted_access_token"
+	userResourceKeyFingerprintAttrKey       = "key_fingerprint"
+)
+
+const (
+	awsCredentialsResourceAccountIDAttrKey  = "account_id"
+	awsCredentialsResourceRoleArnAttrKey    = "role_arn"
+	awsCredentialsResourceExternalIDAttrKey = "external_id"
+)
+
+const (
+	awsExternalIDResourceAccountIDAttrKey  = "account_id"
+	awsExternalIDResourceExternalIDAttrKey = "external_id"
+)
+
+const (
+	accountsDataSourceNameRegexAttrKey          = "name_regex"
+	accountsDataSourceCloudProviderAttrKey      = "cloud_provider"
+	accountsDataSourceProviderExternalIDAttrKey = "provider_external_id"
+	accountsDataSourceIDsAttrKey                = "ids"
+	accountsDataSourceAccountsAttrKey           = "accounts"
 )
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/services/accounts"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func dataSourceAccount() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceAccountRead,
+
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(5 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"id": {
+				Type:         schema.TypeString,
+				Description:  "ID of the account to look up",
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"id", accountResourceNameAttrKey},
+			},
+			accountResourceNameAttrKey: {
+				Type:         schema.TypeString,
+				Description:  "Exact name of the account to look up, it must be unique",
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"id", accountResourceNameAttrKey},
+			},
+			accountResourceOrganizationIDAttrKey: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			accountResourceProviderExternalIDAttrKey: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			accountResourceCloudProviderAttrKey: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			accountResourceCreatedAtAttrKey: {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceAccountRead(d *schema.ResourceData, m interface{}) error {
+	accountsService := m.(*Meta).accountsService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutRead))
+	defer cancel()
+
+	var obj *accounts.Account
+	var err error
+
+	if id, ok := d.GetOk("id"); ok {
+		obj, err = accountsService.GetWithContext(ctx, id.(string))
+	} else {
+		obj, err = accountsService.GetByNameWithContext(ctx, d.Get(accountResourceNameAttrKey).(string))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	d.SetId(obj.ID)
+	d.Set(accountResourceNameAttrKey, obj.Name)
+	d.Set(accountResourceOrganizationIDAttrKey, obj.OrganizationID)
+	d.Set(accountResourceProviderExternalIDAttrKey, obj.ProviderExternalID)
+	d.Set(accountResourceCloudProviderAttrKey, obj.CloudProvider)
+	d.Set(accountResourceCreatedAtAttrKey, obj.CreatedAt)
+
+	return nil
+}
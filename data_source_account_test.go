@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func TestAccDataSourceAccount_lookup(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	shared := srv.AddAccount("shared-services")
+	srv.SetAWSCredentials(shared.ID, fakespotinst.AWSCredentials{IAMRole: "arn:aws:iam::111111111111:role/spotinst"})
+	srv.AddAccount("other")
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders(),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(srv) + `
+data "spotinstadmin_account" "by_name" {
+  name = "shared-services"
+}
+
+data "spotinstadmin_account" "by_id" {
+  id = data.spotinstadmin_account.by_name.id
+}
+`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.spotinstadmin_account.by_name", "id", shared.ID),
+					resource.TestCheckResourceAttr("data.spotinstadmin_account.by_name", "provider_external_id", "111111111111"),
+					resource.TestCheckResourceAttr("data.spotinstadmin_account.by_id", "name", "shared-services"),
+					resource.TestCheckResourceAttr("data.spotinstadmin_account.by_id", "cloud_provider", "AWS"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceAccount_errors(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	srv.AddAccount("duplicate")
+	srv.AddAccount("duplicate")
+
+	cases := map[string]struct {
+		config string
+		err    string
+	}{
+		"missing name": {`name = "missing"`, `Account named "missing" not found`},
+		"missing id":   {`id = "act-missing"`, `Account act-missing not found`},
+		"duplicate":    {`name = "duplicate"`, `2 accounts named "duplicate" found`},
+		"no argument":  {``, `one of .id,name. must be specified`},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			resource.UnitTest(t, resource.TestCase{
+				Providers: testProviders(),
+				Steps: []resource.TestStep{
+					{
+						Config:      testProviderConfig(srv) + "data \"spotinstadmin_account\" \"test\" {\n" + tc.config + "\n}\n",
+						ExpectError: regexp.MustCompile(tc.err),
+					},
+				},
+			})
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/services/accounts"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/hashcode"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+func dataSourceAccounts() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceAccountsRead,
+
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(5 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			accountsDataSourceNameRegexAttrKey: {
+				Type:         schema.TypeString,
+				Description:  "Only return accounts whose name matches this regular expression",
+				Optional:     true,
+				ValidateFunc: validation.StringIsValidRegExp,
+			},
+			accountsDataSourceCloudProviderAttrKey: {
+				Type:        schema.TypeString,
+				Description: "Only return accounts connected to this cloud provider, e.g. AWS",
+				Optional:    true,
+			},
+			accountsDataSourceProviderExternalIDAttrKey: {
+				Type:        schema.TypeString,
+				Description: "Only return accounts with this ID at the cloud provider",
+				Optional:    true,
+			},
+			accountsDataSourceIDsAttrKey: {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			accountsDataSourceAccountsAttrKey: {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						accountResourceNameAttrKey: {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						accountResourceOrganizationIDAttrKey: {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						accountResourceProviderExternalIDAttrKey: {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						accountResourceCloudProviderAttrKey: {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						accountResourceCreatedAtAttrKey: {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceAccountsRead(d *schema.ResourceData, m interface{}) error {
+	accountsService := m.(*Meta).accountsService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutRead))
+	defer cancel()
+
+	accountList, err := accountsService.ListWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var nameRegex *regexp.Regexp
+	if v, ok := d.GetOk(accountsDataSourceNameRegexAttrKey); ok {
+		nameRegex = regexp.MustCompile(v.(string))
+	}
+	cloudProvider := d.Get(accountsDataSourceCloudProviderAttrKey).(string)
+	providerExternalID := d.Get(accountsDataSourceProviderExternalIDAttrKey).(string)
+
+	var matches []*accounts.Account
+	for _, a := range accountList {
+		if nameRegex != nil && !nameRegex.MatchString(a.Name) {
+			continue
+		}
+		if cloudProvider != "" && !strings.EqualFold(a.CloudProvider, cloudProvider) {
+			continue
+		}
+		if providerExternalID != "" && a.ProviderExternalID != providerExternalID {
+			continue
+		}
+		matches = append(matches, a)
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+
+	ids := make([]string, len(matches))
+	flattened := make([]map[string]interface{}, len(matches))
+	for i, a := range matches {
+		ids[i] = a.ID
+		flattened[i] = flattenAccount(a)
+	}
+
+	d.SetId(strconv.Itoa(hashcode.String(strings.Join(ids, ","))))
+
+	if err := d.Set(accountsDataSourceIDsAttrKey, ids); err != nil {
+		return err
+	}
+	return d.Set(accountsDataSourceAccountsAttrKey, flattened)
+}
+
+func flattenAccount(a *accounts.Account) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                                     a.ID,
+		accountResourceNameAttrKey:               a.Name,
+		accountResourceOrganizationIDAttrKey:     a.OrganizationID,
+		accountResourceProviderExternalIDAttrKey: a.ProviderExternalID,
+		accountResourceCloudProviderAttrKey:      a.CloudProvider,
+		accountResourceCreatedAtAttrKey:          a.CreatedAt,
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func TestAccDataSourceAccounts_filters(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	prodAWS := srv.AddAccount("prod-aws")
+	srv.SetAWSCredentials(prodAWS.ID, fakespotinst.AWSCredentials{IAMRole: "arn:aws:iam::111111111111:role/spotinst"})
+	prodOther := srv.AddAccount("prod-other")
+	srv.SetAWSCredentials(prodOther.ID, fakespotinst.AWSCredentials{IAMRole: "arn:aws:iam::222222222222:role/spotinst"})
+	srv.AddAccount("prod-unconnected")
+	srv.AddAccount("staging")
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders(),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(srv) + `
+data "spotinstadmin_accounts" "all" {}
+
+data "spotinstadmin_accounts" "prod" {
+  name_regex = "^prod-"
+}
+
+data "spotinstadmin_accounts" "prod_aws" {
+  name_regex     = "^prod-"
+  cloud_provider = "aws"
+}
+
+data "spotinstadmin_accounts" "by_external_id" {
+  provider_external_id = "222222222222"
+}
+
+data "spotinstadmin_accounts" "none" {
+  name_regex = "^dev-"
+}
+`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.spotinstadmin_accounts.all", "ids.#", "4"),
+					resource.TestCheckResourceAttr("data.spotinstadmin_accounts.prod", "ids.#", "3"),
+					resource.TestCheckResourceAttr("data.spotinstadmin_accounts.prod_aws", "ids.#", "2"),
+					resource.TestCheckResourceAttr("data.spotinstadmin_accounts.prod_aws", "accounts.0.id", prodAWS.ID),
+					resource.TestCheckResourceAttr("data.spotinstadmin_accounts.prod_aws", "accounts.0.cloud_provider", "AWS"),
+					resource.TestCheckResourceAttr("data.spotinstadmin_accounts.prod_aws", "accounts.0.organization_id", fakespotinst.DefaultOrganizationID),
+					resource.TestCheckResourceAttr("data.spotinstadmin_accounts.by_external_id", "ids.#", "1"),
+					resource.TestCheckResourceAttr("data.spotinstadmin_accounts.by_external_id", "accounts.0.name", "prod-other"),
+					resource.TestCheckResourceAttr("data.spotinstadmin_accounts.none", "ids.#", "0"),
+				),
+			},
+		},
+	})
+}
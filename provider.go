@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
 	"github.com/cnicolov/terraform-provider-spotinstadmin/services/accounts"
 	"github.com/cnicolov/terraform-provider-spotinstadmin/services/users"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 )
 
 // Provider ...
 func Provider() *schema.Provider {
-	return &schema.Provider{
+	p := &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			providerTokenAttrKey: &schema.Schema{
 				Type:        schema.TypeString,
@@ -28,33 +33,113 @@ func Provider() *schema.Provider {
 				Sensitive:   true,
 				DefaultFunc: schema.EnvDefaultFunc(envSpotinstPasswordKey, nil),
 			},
+			providerMaxRetriesAttrKey: &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      4,
+				Description:  "How many times a rate limited or failed API request is retried",
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			providerMaxRetryWaitAttrKey: &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      30,
+				Description:  "Maximum number of seconds to wait between two retries",
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			providerAccountsEndpointAttrKey: &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "Base URL of the Spotinst accounts API, defaults to https://api.spotinst.io",
+				DefaultFunc:  schema.EnvDefaultFunc(envSpotinstAccountsEndpointKey, nil),
+				ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+			},
+			providerUsersEndpointAttrKey: &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "Base URL of the Spotinst users API, defaults to https://console.spotinst.com",
+				DefaultFunc:  schema.EnvDefaultFunc(envSpotinstUsersEndpointKey, nil),
+				ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+			},
+			providerSignInEndpointAttrKey: &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "Base URL used to sign in with email and password, defaults to users_endpoint",
+				DefaultFunc:  schema.EnvDefaultFunc(envSpotinstSignInEndpointKey, nil),
+				ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+			},
+		},
+		DataSourcesMap: map[string]*schema.Resource{
+			accountDataSourceName:  dataSourceAccount(),
+			accountsDataSourceName: dataSourceAccounts(),
 		},
 		ResourcesMap: map[string]*schema.Resource{
 			accountResourceName:          resourceAccount(),
 			programmaticUserResourceName: resourceProgrammaticUser(),
+			awsCredentialsResourceName:   resourceAWSCredentials(),
+			awsExternalIDResourceName:    resourceAWSExternalID(),
 		},
-		ConfigureFunc: providerConfigureFunc,
 	}
+	p.ConfigureFunc = providerConfigureFunc(p)
+	return p
 }
 
 // Meta ...
 type Meta struct {
 	accountsService *accounts.Service
 	usersService    *users.Service
+
+	// stopCtx is cancelled when Terraform asks the provider to stop
+	stopCtx context.Context
+}
+
+// requestContext returns a context for API calls which is cancelled when
+// Terraform stops the provider or once timeout elapses
+func (m *Meta) requestContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(m.stopCtx, timeout)
+}
+
+func providerConfigureFunc(p *schema.Provider) schema.ConfigureFunc {
+	return func(d *schema.ResourceData) (interface{}, error) {
+		return providerConfigure(d, p.StopContext())
+	}
 }
 
-func providerConfigureFunc(d *schema.ResourceData) (interface{}, error) {
+func providerConfigure(d *schema.ResourceData, stopCtx context.Context) (interface{}, error) {
 	apiToken := d.Get(providerTokenAttrKey).(string)
 	username := d.Get(providerEmailAttrKey).(string)
 	password := d.Get(providerPasswordAttrKey).(string)
-	consoleToken, err := users.GetConsoleToken(username, password)
+	retries := client.WithRetries(
+		d.Get(providerMaxRetriesAttrKey).(int),
+		time.Duration(d.Get(providerMaxRetryWaitAttrKey).(int))*time.Second,
+	)
+
+	accountsEndpoint := d.Get(providerAccountsEndpointAttrKey).(string)
+	usersEndpoint := d.Get(providerUsersEndpointAttrKey).(string)
+	signInEndpoint := d.Get(providerSignInEndpointAttrKey).(string)
+	if signInEndpoint == "" {
+		signInEndpoint = usersEndpoint
+	}
+
+	consoleToken, err := users.GetConsoleTokenWithContext(stopCtx, username, password, retries, client.WithBaseURL(signInEndpoint))
+
+	if err != nil {
+		return nil, err
+	}
+
+	accountsService, err := accounts.New(apiToken, retries, client.WithBaseURL(accountsEndpoint))
+	if err != nil {
+		return nil, err
+	}
 
+	usersService, err := users.New(consoleToken, retries, client.WithBaseURL(usersEndpoint))
 	if err != nil {
 		return nil, err
 	}
 
 	return &Meta{
-		accountsService: accounts.New(apiToken),
-		usersService:    users.New(consoleToken),
+		accountsService: accountsService,
+		usersService:    usersService,
+		stopCtx:         stopCtx,
 	}, nil
 }
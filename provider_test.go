@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/services/accounts"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/services/users"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestProvider(t *testing.T) {
+	if err := Provider().InternalValidate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testProviders() map[string]terraform.ResourceProvider {
+	return map[string]terraform.ResourceProvider{
+		providerName: Provider(),
+	}
+}
+
+// testProviderConfig points every endpoint of the provider at srv
+func testProviderConfig(srv *fakespotinst.Server) string {
+	return fmt.Sprintf(`
+provider %q {
+  token             = %q
+  email             = %q
+  password          = %q
+  accounts_endpoint = %q
+  users_endpoint    = %q
+  max_retry_wait    = 1
+}
+`, providerName, srv.APIToken, srv.Email, srv.Password, srv.URL, srv.URL)
+}
+
+// testMeta builds the provider meta against srv, for tests calling
+// CRUD functions directly
+func testMeta(t *testing.T, srv *fakespotinst.Server) *Meta {
+	opts := []client.Option{client.WithBaseURL(srv.URL), client.WithRetries(1, time.Millisecond)}
+
+	accountsService, err := accounts.New(srv.APIToken, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usersService, err := users.New(srv.ConsoleToken, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Meta{
+		accountsService: accountsService,
+		usersService:    usersService,
+		stopCtx:         context.Background(),
+	}
+}
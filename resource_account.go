@@ -1,6 +1,12 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
 	"github.com/cnicolov/terraform-provider-spotinstadmin/services/accounts"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
@@ -12,22 +18,105 @@ func resourceAccount() *schema.Resource {
 		Update: resourceAccountUpdate,
 		Delete: resourceAccountDelete,
 
+		CustomizeDiff: resourceAccountCustomizeDiff,
+
+		// aws_external_id is write-only in the API and has to be set
+		// in the configuration after importing
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+
 		Schema: map[string]*schema.Schema{
 			accountResourceNameAttrKey: &schema.Schema{
 				Type:     schema.TypeString,
 				Required: true,
-				ForceNew: true,
 			},
 
 			accountResourceRoleArnAttrKey: {
-				Type:        schema.TypeString,
-				Description: "AWS Role arn to assume",
-				Required:    true,
+				Type:          schema.TypeString,
+				Description:   "AWS Role arn to assume",
+				Optional:      true,
+				ConflictsWith: []string{accountResourceAzureCredentialsAttrKey, accountResourceGCPCredentialsAttrKey},
 			},
 			accountResourceExternalIDAttrKey: {
+				Type:          schema.TypeString,
+				Description:   "ExternalID to use",
+				Optional:      true,
+				ConflictsWith: []string{accountResourceAzureCredentialsAttrKey, accountResourceGCPCredentialsAttrKey},
+			},
+			accountResourceAzureCredentialsAttrKey: {
+				Type:          schema.TypeList,
+				Description:   "Azure service principal to connect the account with",
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{accountResourceRoleArnAttrKey, accountResourceExternalIDAttrKey, accountResourceGCPCredentialsAttrKey},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						azureCredentialsTenantIDAttrKey: {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						azureCredentialsClientIDAttrKey: {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						azureCredentialsClientSecretAttrKey: {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+						azureCredentialsSubscriptionIDAttrKey: {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+					},
+				},
+			},
+			accountResourceGCPCredentialsAttrKey: {
+				Type:          schema.TypeList,
+				Description:   "GCP service account to connect the account with",
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{accountResourceRoleArnAttrKey, accountResourceExternalIDAttrKey, accountResourceAzureCredentialsAttrKey},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						gcpCredentialsServiceAccountJSONAttrKey: {
+							Type:         schema.TypeString,
+							Description:  "Content of the service account key file",
+							Required:     true,
+							Sensitive:    true,
+							ValidateFunc: validateGCPServiceAccountJSON,
+						},
+					},
+				},
+			},
+
+			accountResourceOrganizationIDAttrKey: {
 				Type:        schema.TypeString,
-				Description: "ExternalID to use",
-				Required:    true,
+				Description: "ID of the organization the account belongs to",
+				Computed:    true,
+			},
+			accountResourceProviderExternalIDAttrKey: {
+				Type:        schema.TypeString,
+				Description: "ID of the account at the cloud provider",
+				Computed:    true,
+			},
+			accountResourceCloudProviderAttrKey: {
+				Type:        schema.TypeString,
+				Description: "Cloud provider the account is connected to",
+				Computed:    true,
+			},
+			accountResourceCreatedAtAttrKey: {
+				Type:        schema.TypeString,
+				Description: "Time the account was created at",
+				Computed:    true,
 			},
 		},
 	}
@@ -35,11 +124,13 @@ func resourceAccount() *schema.Resource {
 
 func resourceAccountCreate(d *schema.ResourceData, m interface{}) error {
 	accountsService := m.(*Meta).accountsService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutCreate))
+	defer cancel()
+
 	name := d.Get(accountResourceNameAttrKey).(string)
-	iamRole := d.Get(accountResourceRoleArnAttrKey).(string)
-	externalID := d.Get(accountResourceExternalIDAttrKey).(string)
+	connect, _ := accountConnectFunc(d, accountsService)
 
-	out, err := accountsService.Create(name, iamRole, externalID)
+	out, err := accountsService.CreateWithCredentialsContext(ctx, name, connect)
 
 	if err != nil {
 		return err
@@ -47,14 +138,19 @@ func resourceAccountCreate(d *schema.ResourceData, m interface{}) error {
 
 	d.SetId(out.ID)
 
-	d.Set("organization_id", out.OrganizationID)
+	d.Set(accountResourceOrganizationIDAttrKey, out.OrganizationID)
 
 	return resourceAccountRead(d, m)
 }
 
 func resourceAccountRead(d *schema.ResourceData, m interface{}) error {
 	accountsService := m.(*Meta).accountsService
-	obj, err := accountsService.Get(d.Id())
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutRead))
+	defer cancel()
+
+	managesAWSCredentials := accountManagesAWSCredentials(d)
+
+	obj, err := accountsService.GetWithContext(ctx, d.Id())
 	if err != nil {
 		if accounts.IsAccountNotFoundErr(err) {
 			d.SetId("")
@@ -64,17 +160,188 @@ func resourceAccountRead(d *schema.ResourceData, m interface{}) error {
 	}
 
 	d.Set(accountResourceNameAttrKey, obj.Name)
-	d.Set("organization_id", obj.OrganizationID)
-	d.Set("provider_external_id", obj.ProviderExternalID)
+	d.Set(accountResourceOrganizationIDAttrKey, obj.OrganizationID)
+	d.Set(accountResourceProviderExternalIDAttrKey, obj.ProviderExternalID)
+	d.Set(accountResourceCloudProviderAttrKey, obj.CloudProvider)
+	d.Set(accountResourceCreatedAtAttrKey, obj.CreatedAt)
+
+	// Azure and GCP credentials are write-only
+	if expandAzureCredentials(d) != nil || expandGCPServiceAccount(d) != "" {
+		return nil
+	}
+
+	// Credentials set up by spotinstadmin_aws_credentials are left to it
+	if !managesAWSCredentials {
+		return nil
+	}
+
+	// Only the role ARN is returned, changes of the external ID
+	// made outside of Terraform can't be detected
+	creds, err := accountsService.GetAWSCredentialsWithContext(ctx, d.Id())
+	switch {
+	case err == nil:
+		d.Set(accountResourceRoleArnAttrKey, creds.IAMRole)
+	case client.IsNotFound(err):
+		d.Set(accountResourceRoleArnAttrKey, "")
+	default:
+		return err
+	}
 
 	return nil
 }
 
 func resourceAccountUpdate(d *schema.ResourceData, m interface{}) error {
+	accountsService := m.(*Meta).accountsService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutUpdate))
+	defer cancel()
+
+	if d.HasChange(accountResourceNameAttrKey) {
+		name := d.Get(accountResourceNameAttrKey).(string)
+
+		_, err := accountsService.UpdateWithContext(ctx, d.Id(), name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if connect, keys := accountConnectFunc(d, accountsService); connect != nil && d.HasChanges(keys...) {
+		err := connect(ctx, d.Id())
+		if err != nil {
+			return err
+		}
+	}
+
 	return resourceAccountRead(d, m)
 }
 
 func resourceAccountDelete(d *schema.ResourceData, m interface{}) error {
 	accountsService := m.(*Meta).accountsService
-	return accountsService.Delete(d.Id())
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutDelete))
+	defer cancel()
+
+	err := accountsService.DeleteWithContext(ctx, d.Id())
+	if client.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// resourceAccountCustomizeDiff checks aws_role_arn and aws_external_id are
+// set together, which the API requires
+func resourceAccountCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown(accountResourceRoleArnAttrKey) || !d.NewValueKnown(accountResourceExternalIDAttrKey) {
+		return nil
+	}
+
+	iamRole := d.Get(accountResourceRoleArnAttrKey).(string)
+	externalID := d.Get(accountResourceExternalIDAttrKey).(string)
+
+	if (iamRole == "") != (externalID == "") {
+		return fmt.Errorf("%q and %q have to be set together", accountResourceRoleArnAttrKey, accountResourceExternalIDAttrKey)
+	}
+	return nil
+}
+
+// accountManagesAWSCredentials tells if the role ARN is tracked by the
+// account. Accounts created without it leave the credentials to
+// spotinstadmin_aws_credentials. An imported account has nothing but
+// its ID in the state yet, so the role ARN is read back.
+func accountManagesAWSCredentials(d *schema.ResourceData) bool {
+	if d.Get(accountResourceRoleArnAttrKey).(string) != "" {
+		return true
+	}
+	return d.Get(accountResourceNameAttrKey).(string) == ""
+}
+
+// accountConnectFunc returns a function setting up whichever cloud
+// credentials are configured, along with the attributes they come from.
+// It returns nil when the account is created without credentials.
+func accountConnectFunc(d *schema.ResourceData, accountsService *accounts.Service) (accounts.ConnectFunc, []string) {
+	if azureCreds := expandAzureCredentials(d); azureCreds != nil {
+		return func(ctx context.Context, accountID string) error {
+			return accountsService.SetAzureCredentialsWithContext(ctx, accountID, azureCreds)
+		}, []string{accountResourceAzureCredentialsAttrKey}
+	}
+
+	if serviceAccount := expandGCPServiceAccount(d); serviceAccount != "" {
+		return func(ctx context.Context, accountID string) error {
+			return accountsService.SetGCPCredentialsWithContext(ctx, accountID, serviceAccount)
+		}, []string{accountResourceGCPCredentialsAttrKey}
+	}
+
+	iamRole := d.Get(accountResourceRoleArnAttrKey).(string)
+	externalID := d.Get(accountResourceExternalIDAttrKey).(string)
+
+	if iamRole == "" && externalID == "" {
+		return nil, nil
+	}
+
+	return func(ctx context.Context, accountID string) error {
+		return accountsService.SetAWSCredentialsWithContext(ctx, accountID, iamRole, externalID)
+	}, []string{accountResourceRoleArnAttrKey, accountResourceExternalIDAttrKey}
+}
+
+func expandAzureCredentials(d *schema.ResourceData) *accounts.AzureCredentials {
+	l := d.Get(accountResourceAzureCredentialsAttrKey).([]interface{})
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+
+	m := l[0].(map[string]interface{})
+
+	return &accounts.AzureCredentials{
+		TenantID:       m[azureCredentialsTenantIDAttrKey].(string),
+		ClientID:       m[azureCredentialsClientIDAttrKey].(string),
+		ClientSecret:   m[azureCredentialsClientSecretAttrKey].(string),
+		SubscriptionID: m[azureCredentialsSubscriptionIDAttrKey].(string),
+	}
+}
+
+func expandGCPServiceAccount(d *schema.ResourceData) string {
+	l := d.Get(accountResourceGCPCredentialsAttrKey).([]interface{})
+	if len(l) == 0 || l[0] == nil {
+		return ""
+	}
+
+	m := l[0].(map[string]interface{})
+
+	return m[gcpCredentialsServiceAccountJSONAttrKey].(string)
+}
+
+// gcpServiceAccountRequiredKeys are the keys of a service account key
+// file the Spotinst API needs to authenticate against GCP
+var gcpServiceAccountRequiredKeys = []string{
+	"type",
+	"project_id",
+	"private_key_id",
+	"private_key",
+	"client_email",
+	"client_id",
+}
+
+func validateGCPServiceAccountJSON(i interface{}, k string) ([]string, []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %q to be string", k)}
+	}
+
+	var key map[string]interface{}
+	if err := json.Unmarshal([]byte(v), &key); err != nil {
+		// The value is sensitive, and decoder errors can quote
+		// parts of it, so neither is part of the error
+		return nil, []error{fmt.Errorf("%q is not a valid JSON object", k)}
+	}
+
+	var errs []error
+	for _, required := range gcpServiceAccountRequiredKeys {
+		if s, _ := key[required].(string); s == "" {
+			errs = append(errs, fmt.Errorf("%q is missing the %q key", k, required))
+		}
+	}
+
+	if t, _ := key["type"].(string); t != "" && t != "service_account" {
+		errs = append(errs, fmt.Errorf("%q must be a service account key, got type %q", k, t))
+	}
+
+	return nil, errs
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccAccount_azure(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	var accountID string
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckAccountDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAccountAzureConfig(srv, "secret-1"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckAccountID("spotinstadmin_account.test", &accountID),
+					testAccCheckAccountAzureCredentials(srv, "spotinstadmin_account.test", "secret-1"),
+					resource.TestCheckResourceAttr("spotinstadmin_account.test", "cloud_provider", "AZURE"),
+					resource.TestCheckResourceAttr("spotinstadmin_account.test", "provider_external_id", "sub-1"),
+					resource.TestCheckNoResourceAttr("spotinstadmin_account.test", "aws_role_arn"),
+				),
+			},
+			{
+				Config: testAccAccountAzureConfig(srv, "secret-2"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckAccountAzureCredentials(srv, "spotinstadmin_account.test", "secret-2"),
+					func(s *terraform.State) error {
+						if id := s.RootModule().Resources["spotinstadmin_account.test"].Primary.ID; id != accountID {
+							return fmt.Errorf("expected account %s to be updated in place, got %s", accountID, id)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccAccount_azureSetupFailure(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	srv.InjectFault(fakespotinst.Fault{Path: "/azure/setup/credentials", StatusCode: http.StatusBadRequest})
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders(),
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccAccountAzureConfig(srv, "secret-1"),
+				ExpectError: regexp.MustCompile("failed setting up Azure credentials"),
+			},
+		},
+	})
+
+	if n := len(srv.Accounts()); n != 0 {
+		t.Fatalf("expected the account to be deleted after failing to set up credentials, got %d accounts", n)
+	}
+}
+
+func TestAccAccount_conflictingCredentials(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders(),
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig(srv) + `
+resource "spotinstadmin_account" "test" {
+  name         = "both"
+  aws_role_arn = "arn:aws:iam::123456789012:role/spotinst"
+
+  azure_credentials {
+    tenant_id       = "tenant-1"
+    client_id       = "client-1"
+    client_secret   = "secret-1"
+    subscription_id = "sub-1"
+  }
+}
+`,
+				ExpectError: regexp.MustCompile("conflicts with"),
+			},
+		},
+	})
+}
+
+func testAccAccountAzureConfig(srv *fakespotinst.Server, clientSecret string) string {
+	return testProviderConfig(srv) + fmt.Sprintf(`
+resource "spotinstadmin_account" "test" {
+  name = "azure-account"
+
+  azure_credentials {
+    tenant_id       = "tenant-1"
+    client_id       = "client-1"
+    client_secret   = %q
+    subscription_id = "sub-1"
+  }
+}
+`, clientSecret)
+}
+
+func testAccCheckAccountAzureCredentials(srv *fakespotinst.Server, n, clientSecret string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+		a, _ := srv.Account(rs.Primary.ID)
+		if a.Azure == nil {
+			return fmt.Errorf("Account %s has no Azure credentials", rs.Primary.ID)
+		}
+		expected := fakespotinst.AzureCredentials{TenantID: "tenant-1", ClientID: "client-1", ClientSecret: clientSecret, SubscriptionID: "sub-1"}
+		if *a.Azure != expected {
+			return fmt.Errorf("Account %s has unexpected Azure credentials %+v", rs.Primary.ID, *a.Azure)
+		}
+		return nil
+	}
+}
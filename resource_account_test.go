@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccAccount_basic(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckAccountDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAccountConfig(srv, "test-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckAccountExists(srv, "spotinstadmin_account.test"),
+					testAccCheckAccountCredentials(srv, "spotinstadmin_account.test", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+					resource.TestCheckResourceAttr("spotinstadmin_account.test", "name", "test-account"),
+					resource.TestCheckResourceAttr("spotinstadmin_account.test", "organization_id", fakespotinst.DefaultOrganizationID),
+					resource.TestCheckResourceAttr("spotinstadmin_account.test", "provider_external_id", "123456789012"),
+					resource.TestCheckResourceAttr("spotinstadmin_account.test", "cloud_provider", "AWS"),
+					resource.TestCheckResourceAttrSet("spotinstadmin_account.test", "created_at"),
+				),
+			},
+			{
+				Config:            testAccAccountConfig(srv, "test-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				ResourceName:      "spotinstadmin_account.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				// The external ID is write-only in the API
+				ImportStateVerifyIgnore: []string{"aws_external_id"},
+			},
+		},
+	})
+}
+
+func TestAccAccount_rename(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	var accountID string
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckAccountDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAccountConfig(srv, "tpyo-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				Check:  testAccCheckAccountID("spotinstadmin_account.test", &accountID),
+			},
+			{
+				Config: testAccAccountConfig(srv, "typo-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("spotinstadmin_account.test", "name", "typo-account"),
+					func(s *terraform.State) error {
+						id := s.RootModule().Resources["spotinstadmin_account.test"].Primary.ID
+						if id != accountID {
+							return fmt.Errorf("expected account %s to be renamed in place, got %s", accountID, id)
+						}
+						if a, _ := srv.Account(id); a.Name != "typo-account" {
+							return fmt.Errorf("expected account %s to be renamed in the API, got %q", id, a.Name)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccAccount_updateCredentials(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckAccountDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAccountConfig(srv, "test-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				Check:  testAccCheckAccountCredentials(srv, "spotinstadmin_account.test", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+			},
+			{
+				Config: testAccAccountConfig(srv, "test-account", "arn:aws:iam::123456789012:role/rotated", "ext-2"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckAccountCredentials(srv, "spotinstadmin_account.test", "arn:aws:iam::123456789012:role/rotated", "ext-2"),
+					resource.TestCheckResourceAttr("spotinstadmin_account.test", "aws_role_arn", "arn:aws:iam::123456789012:role/rotated"),
+				),
+			},
+			{
+				// Credentials changed in the console show up as a diff
+				PreConfig: func() {
+					for _, a := range srv.Accounts() {
+						srv.SetAWSCredentials(a.ID, fakespotinst.AWSCredentials{IAMRole: "arn:aws:iam::123456789012:role/console", ExternalID: "ext-2"})
+					}
+				},
+				Config:             testAccAccountConfig(srv, "test-account", "arn:aws:iam::123456789012:role/rotated", "ext-2"),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccAccountConfig(srv, "test-account", "arn:aws:iam::123456789012:role/rotated", "ext-2"),
+				Check:  testAccCheckAccountCredentials(srv, "spotinstadmin_account.test", "arn:aws:iam::123456789012:role/rotated", "ext-2"),
+			},
+		},
+	})
+}
+
+func TestAccAccount_awsCredentialsSetTogether(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckAccountDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccAccountConfig(srv, "test-account", "arn:aws:iam::123456789012:role/spotinst", ""),
+				ExpectError: regexp.MustCompile(`"aws_role_arn" and "aws_external_id" have to be set together`),
+			},
+			{
+				Config: testAccAccountConfig(srv, "test-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				Check:  testAccCheckAccountCredentials(srv, "spotinstadmin_account.test", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+			},
+			{
+				// Removing the credentials from the configuration isn't
+				// hidden by the role ARN read back from the API
+				Config:             testAccAccountConfig(srv, "test-account", "", ""),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccAccountConfig(srv, "test-account", "", ""),
+				Check:  resource.TestCheckResourceAttr("spotinstadmin_account.test", "aws_role_arn", ""),
+			},
+		},
+	})
+}
+
+func TestAccAccount_importConsoleAccount(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	existing := srv.AddAccount("console-account")
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders(),
+		Steps: []resource.TestStep{
+			{
+				Config:        testAccAccountConfig(srv, "console-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				ResourceName:  "spotinstadmin_account.test",
+				ImportState:   true,
+				ImportStateId: existing.ID,
+				ImportStateCheck: func(states []*terraform.InstanceState) error {
+					if len(states) != 1 {
+						return fmt.Errorf("expected 1 imported state, got %d", len(states))
+					}
+					attrs := states[0].Attributes
+					if attrs["name"] != "console-account" || attrs["organization_id"] != fakespotinst.DefaultOrganizationID {
+						return fmt.Errorf("unexpected imported attributes %v", attrs)
+					}
+					if attrs["aws_role_arn"] != "" {
+						return fmt.Errorf("expected no role ARN for an account without credentials, got %q", attrs["aws_role_arn"])
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestAccAccount_eventualConsistency(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	srv.SetListLag(2)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckAccountDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAccountConfig(srv, "lagging-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckAccountExists(srv, "spotinstadmin_account.test"),
+					testAccCheckAccountCredentials(srv, "spotinstadmin_account.test", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccAccount_retriesServerErrors(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	srv.InjectFault(fakespotinst.Fault{Method: http.MethodPost, Path: "/setup/account", StatusCode: http.StatusTooManyRequests, RetryAfter: "0", Times: 2})
+	srv.InjectFault(fakespotinst.Fault{Method: http.MethodPost, Path: "/setup/credentials/aws", StatusCode: http.StatusInternalServerError, Times: 1})
+	srv.InjectFault(fakespotinst.Fault{Method: http.MethodGet, Path: "/setup/account", StatusCode: http.StatusServiceUnavailable, Times: 1})
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckAccountDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAccountConfig(srv, "flaky-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckAccountExists(srv, "spotinstadmin_account.test"),
+					testAccCheckAccountCredentials(srv, "spotinstadmin_account.test", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				),
+			},
+		},
+	})
+
+	if n := len(srv.Accounts()); n != 0 {
+		t.Fatalf("expected no accounts to be left behind, got %d", n)
+	}
+}
+
+func testAccAccountConfig(srv *fakespotinst.Server, name, roleArn, externalID string) string {
+	return testProviderConfig(srv) + fmt.Sprintf(`
+resource "spotinstadmin_account" "test" {
+  name            = %q
+  aws_role_arn    = %q
+  aws_external_id = %q
+}
+`, name, roleArn, externalID)
+}
+
+func testAccCheckAccountExists(srv *fakespotinst.Server, n string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+		if _, ok := srv.Account(rs.Primary.ID); !ok {
+			return fmt.Errorf("Account %s does not exist in the API", rs.Primary.ID)
+		}
+		return nil
+	}
+}
+
+func testAccCheckAccountCredentials(srv *fakespotinst.Server, n, roleArn, externalID string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+		a, _ := srv.Account(rs.Primary.ID)
+		if a.Credentials == nil {
+			return fmt.Errorf("Account %s has no AWS credentials", rs.Primary.ID)
+		}
+		if a.Credentials.IAMRole != roleArn || a.Credentials.ExternalID != externalID {
+			return fmt.Errorf("Account %s has unexpected AWS credentials %+v", rs.Primary.ID, *a.Credentials)
+		}
+		return nil
+	}
+}
+
+func testAccCheckAccountDestroy(srv *fakespotinst.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != accountResourceName {
+				continue
+			}
+			if _, ok := srv.Account(rs.Primary.ID); ok {
+				return fmt.Errorf("Account %s still exists", rs.Primary.ID)
+			}
+		}
+		return nil
+	}
+}
+
+func testAccCheckAccountID(n string, id *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+		*id = rs.Primary.ID
+		return nil
+	}
+}
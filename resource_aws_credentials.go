@@ -0,0 +1,116 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// resourceAWSCredentials connects an existing account to AWS. It's meant for
+// accounts created without aws_role_arn, e.g. when the IAM role is created
+// in the same apply as the account.
+func resourceAWSCredentials() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceAWSCredentialsCreate,
+		Read:   resourceAWSCredentialsRead,
+		Update: resourceAWSCredentialsUpdate,
+		Delete: resourceAWSCredentialsDelete,
+
+		// external_id is write-only in the API and has to be set
+		// in the configuration after importing
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			awsCredentialsResourceAccountIDAttrKey: {
+				Type:        schema.TypeString,
+				Description: "ID of the Spotinst account to connect",
+				Required:    true,
+				ForceNew:    true,
+			},
+			awsCredentialsResourceRoleArnAttrKey: {
+				Type:        schema.TypeString,
+				Description: "AWS Role arn to assume",
+				Required:    true,
+			},
+			awsCredentialsResourceExternalIDAttrKey: {
+				Type:        schema.TypeString,
+				Description: "ExternalID to use",
+				Required:    true,
+			},
+		},
+	}
+}
+
+func resourceAWSCredentialsCreate(d *schema.ResourceData, m interface{}) error {
+	accountsService := m.(*Meta).accountsService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutCreate))
+	defer cancel()
+
+	accountID := d.Get(awsCredentialsResourceAccountIDAttrKey).(string)
+	iamRole := d.Get(awsCredentialsResourceRoleArnAttrKey).(string)
+	externalID := d.Get(awsCredentialsResourceExternalIDAttrKey).(string)
+
+	err := accountsService.SetAWSCredentialsWithContext(ctx, accountID, iamRole, externalID)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(accountID)
+
+	return resourceAWSCredentialsRead(d, m)
+}
+
+func resourceAWSCredentialsRead(d *schema.ResourceData, m interface{}) error {
+	accountsService := m.(*Meta).accountsService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutRead))
+	defer cancel()
+
+	creds, err := accountsService.GetAWSCredentialsWithContext(ctx, d.Id())
+	if err != nil {
+		if client.IsNotFound(err) {
+			log.Printf("AWS credentials of account %s are gone, removing from state\n", d.Id())
+			d.SetId("")
+			return nil
+		}
+		return err
+	}
+
+	d.Set(awsCredentialsResourceAccountIDAttrKey, d.Id())
+	d.Set(awsCredentialsResourceRoleArnAttrKey, creds.IAMRole)
+
+	return nil
+}
+
+func resourceAWSCredentialsUpdate(d *schema.ResourceData, m interface{}) error {
+	accountsService := m.(*Meta).accountsService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutUpdate))
+	defer cancel()
+
+	iamRole := d.Get(awsCredentialsResourceRoleArnAttrKey).(string)
+	externalID := d.Get(awsCredentialsResourceExternalIDAttrKey).(string)
+
+	err := accountsService.SetAWSCredentialsWithContext(ctx, d.Id(), iamRole, externalID)
+	if err != nil {
+		return err
+	}
+
+	return resourceAWSCredentialsRead(d, m)
+}
+
+// resourceAWSCredentialsDelete only forgets the credentials, the Spotinst
+// API has no way to disconnect an account from AWS. Deleting the account
+// or the IAM role revokes access.
+func resourceAWSCredentialsDelete(d *schema.ResourceData, m interface{}) error {
+	log.Printf("Removing AWS credentials of account %s from state, they stay set up in Spotinst\n", d.Id())
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func TestAccAWSCredentials_basic(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckAccountDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAWSCredentialsConfig(srv, "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckAccountCredentials(srv, "spotinstadmin_account.test", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+					resource.TestCheckResourceAttrPair("spotinstadmin_aws_credentials.test", "id", "spotinstadmin_account.test", "id"),
+					resource.TestCheckResourceAttr("spotinstadmin_aws_credentials.test", "role_arn", "arn:aws:iam::123456789012:role/spotinst"),
+				),
+			},
+			{
+				Config: testAccAWSCredentialsConfig(srv, "arn:aws:iam::123456789012:role/rotated", "ext-2"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckAccountCredentials(srv, "spotinstadmin_account.test", "arn:aws:iam::123456789012:role/rotated", "ext-2"),
+					resource.TestCheckResourceAttr("spotinstadmin_aws_credentials.test", "role_arn", "arn:aws:iam::123456789012:role/rotated"),
+				),
+			},
+			{
+				Config:                  testAccAWSCredentialsConfig(srv, "arn:aws:iam::123456789012:role/rotated", "ext-2"),
+				ResourceName:            "spotinstadmin_aws_credentials.test",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"external_id"},
+			},
+		},
+	})
+}
+
+func TestAccAWSCredentials_changedOutsideTerraform(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	account := srv.AddAccount("console-account")
+
+	config := testProviderConfig(srv) + fmt.Sprintf(`
+resource "spotinstadmin_aws_credentials" "test" {
+  account_id  = %q
+  role_arn    = "arn:aws:iam::123456789012:role/spotinst"
+  external_id = "ext-1"
+}
+`, account.ID)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders(),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			{
+				PreConfig: func() {
+					srv.SetAWSCredentials(account.ID, fakespotinst.AWSCredentials{IAMRole: "arn:aws:iam::123456789012:role/console", ExternalID: "ext-1"})
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func testAccAWSCredentialsConfig(srv *fakespotinst.Server, roleArn, externalID string) string {
+	return testProviderConfig(srv) + fmt.Sprintf(`
+resource "spotinstadmin_account" "test" {
+  name = "standalone-credentials"
+}
+
+resource "spotinstadmin_aws_credentials" "test" {
+  account_id  = spotinstadmin_account.test.id
+  role_arn    = %q
+  external_id = %q
+}
+`, roleArn, externalID)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/services/accounts"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// resourceAWSExternalID has Spotinst issue the external ID for an account,
+// to be used in the IAM role trust policy and spotinstadmin_aws_credentials.
+// It's a resource rather than a data source so the ID is requested once and
+// stays the same across refreshes.
+func resourceAWSExternalID() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceAWSExternalIDCreate,
+		Read:   resourceAWSExternalIDRead,
+		Delete: resourceAWSExternalIDDelete,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			awsExternalIDResourceAccountIDAttrKey: {
+				Type:        schema.TypeString,
+				Description: "ID of the Spotinst account to issue the external ID for",
+				Required:    true,
+				ForceNew:    true,
+			},
+			awsExternalIDResourceExternalIDAttrKey: {
+				Type:        schema.TypeString,
+				Description: "External ID issued by Spotinst",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceAWSExternalIDCreate(d *schema.ResourceData, m interface{}) error {
+	accountsService := m.(*Meta).accountsService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutCreate))
+	defer cancel()
+
+	accountID := d.Get(awsExternalIDResourceAccountIDAttrKey).(string)
+
+	externalID, err := accountsService.GenerateAWSExternalIDWithContext(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(accountID)
+	d.Set(awsExternalIDResourceExternalIDAttrKey, externalID)
+
+	return resourceAWSExternalIDRead(d, m)
+}
+
+// resourceAWSExternalIDRead only checks the account still exists, the API
+// doesn't return external IDs once issued
+func resourceAWSExternalIDRead(d *schema.ResourceData, m interface{}) error {
+	accountsService := m.(*Meta).accountsService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutRead))
+	defer cancel()
+
+	_, err := accountsService.GetWithContext(ctx, d.Id())
+	if err != nil {
+		if accounts.IsAccountNotFoundErr(err) {
+			d.SetId("")
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+func resourceAWSExternalIDDelete(d *schema.ResourceData, m interface{}) error {
+	return nil
+}
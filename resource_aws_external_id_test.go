@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccAWSExternalID_basic(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	config := testProviderConfig(srv) + `
+resource "spotinstadmin_account" "test" {
+  name = "external-id"
+}
+
+resource "spotinstadmin_aws_external_id" "test" {
+  account_id = spotinstadmin_account.test.id
+}
+
+resource "spotinstadmin_aws_credentials" "test" {
+  account_id  = spotinstadmin_account.test.id
+  role_arn    = "arn:aws:iam::123456789012:role/spotinst"
+  external_id = spotinstadmin_aws_external_id.test.external_id
+}
+`
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckAccountDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("spotinstadmin_aws_external_id.test", "external_id", regexp.MustCompile(`^spotinst:aws:extid:`)),
+					func(s *terraform.State) error {
+						rs := s.RootModule().Resources["spotinstadmin_aws_external_id.test"]
+						a, _ := srv.Account(rs.Primary.ID)
+						if a.Credentials == nil || a.Credentials.ExternalID != rs.Primary.Attributes["external_id"] {
+							return fmt.Errorf("expected account %s to be connected with the issued external ID, got %+v", rs.Primary.ID, a.Credentials)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				// Refreshing must not issue another external ID
+				Config: config,
+				Check: func(*terraform.State) error {
+					if n := srv.RequestCount(http.MethodPost, "/setup/credentials/aws/externalId"); n != 1 {
+						return fmt.Errorf("expected a single external ID to be issued, got %d", n)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
@@ -1,10 +1,21 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/services/users"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/encryption"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 )
 
 func resourceProgrammaticUser() *schema.Resource {
@@ -21,76 +32,344 @@ func resourceProgrammaticUser() *schema.Resource {
 				ForceNew: true,
 			},
 			userResourceDescriptionAttrKey: &schema.Schema{
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
-				Default:  "",
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				Default:          "",
+				DiffSuppressFunc: suppressUnknownValue,
 			},
 			userResourceAccessTokenAttrKey: &schema.Schema{
 				Type:      schema.TypeString,
 				Computed:  true,
 				Sensitive: true,
 			},
+			userResourcePermissionStrategyAttrKey: {
+				Type:         schema.TypeString,
+				Description:  "Whether the user gets an account_role or policy_ids",
+				Optional:     true,
+				ForceNew:     true,
+				Default:      users.PermissionStrategyRoleBased,
+				ValidateFunc: validation.StringInSlice([]string{users.PermissionStrategyRoleBased, users.PermissionStrategyPolicyBased}, false),
+			},
+			userResourceAccountRoleAttrKey: {
+				Type:             schema.TypeString,
+				Description:      "Role of a role based user in the account",
+				Optional:         true,
+				ForceNew:         true,
+				Default:          "editor",
+				ValidateFunc:     validation.StringInSlice(accountRoleNames(), false),
+				DiffSuppressFunc: suppressUnknownValue,
+			},
+			userResourcePolicyIDsAttrKey: {
+				Type:        schema.TypeSet,
+				Description: "Policies of a policy based user",
+				Optional:    true,
+				ForceNew:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IntAtLeast(1),
+				},
+			},
+			userResourcePGPKeyAttrKey: {
+				Type:         schema.TypeString,
+				Description:  "Base64 encoded PGP public key or keybase:<username>, the access token is stored encrypted with it",
+				Optional:     true,
+				ValidateFunc: validatePGPKey,
+			},
+			userResourceEncryptedAccessTokenAttrKey: {
+				Type:        schema.TypeString,
+				Description: "Base64 encoded access token encrypted with pgp_key",
+				Computed:    true,
+			},
+			userResourceKeyFingerprintAttrKey: {
+				Type:        schema.TypeString,
+				Description: "Fingerprint of the PGP key the access token is encrypted with",
+				Computed:    true,
+			},
+		},
+
+		CustomizeDiff: resourceProgrammaticUserCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+
+		Importer: &schema.ResourceImporter{
+			State: resourceProgrammaticUserImport,
+		},
+
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceProgrammaticUserV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceProgrammaticUserStateUpgradeV0,
+			},
 		},
 
 		Create: resourceProgrammaticUserCreate,
 		Read:   resourceProgrammaticUserRead,
-		//		Update: resourceProgrammaticUserUpdate,
+		Update: resourceProgrammaticUserUpdate,
 		Delete: resourceProgrammaticUserDelete,
 	}
 }
 
-func resourceProgrammaticUserRead(d *schema.ResourceData, m interface{}) error {
-	usersService := m.(*Meta).usersService
+// accountRoles maps the account_role values to the roles of the API
+var accountRoles = map[string]int{
+	"viewer": users.AccountRoleViewer,
+	"editor": users.AccountRoleEditor,
+	"admin":  users.AccountRoleAdmin,
+}
 
-	accountID := d.Get(userResourceAccountIDAttrKey).(string)
-	log.Println(accountID)
-	userName := d.Id()
+func accountRoleNames() []string {
+	names := make([]string, 0, len(accountRoles))
+	for name := range accountRoles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
-	log.Printf("IN_RESOURCE_READ: %v-%v\n", userName, accountID)
-	obj, err := usersService.Get(userName, accountID)
+// suppressUnknownValue keeps existing users from being replaced over values
+// the API doesn't return, the description and account role. They're empty in
+// the state of imported users because they're unknown, not because they
+// differ.
+func suppressUnknownValue(k, old, new string, d *schema.ResourceData) bool {
+	return d.Id() != "" && old == ""
+}
+
+// resourceProgrammaticUserCustomizeDiff checks at plan time that the
+// permissions fit the permission strategy and that pgp_key can change
+func resourceProgrammaticUserCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	if d.Id() != "" && d.HasChange(userResourcePGPKeyAttrKey) {
+		// Only a plaintext token can be encrypted again, the API can't
+		// issue another one for an existing user
+		if o, _ := d.GetChange(userResourcePGPKeyAttrKey); o.(string) != "" {
+			return fmt.Errorf("%q can't be changed or removed once set, the access token would have to be issued again", userResourcePGPKeyAttrKey)
+		}
+		if d.Get(userResourceAccessTokenAttrKey).(string) != "" {
+			if err := d.SetNew(userResourceAccessTokenAttrKey, ""); err != nil {
+				return err
+			}
+			for _, k := range []string{userResourceEncryptedAccessTokenAttrKey, userResourceKeyFingerprintAttrKey} {
+				if err := d.SetNewComputed(k); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	policyIDs := d.Get(userResourcePolicyIDsAttrKey).(*schema.Set)
 
+	switch d.Get(userResourcePermissionStrategyAttrKey).(string) {
+	case users.PermissionStrategyPolicyBased:
+		if policyIDs.Len() == 0 && d.NewValueKnown(userResourcePolicyIDsAttrKey) {
+			return fmt.Errorf("%q requires at least one of %q", users.PermissionStrategyPolicyBased, userResourcePolicyIDsAttrKey)
+		}
+	case users.PermissionStrategyRoleBased:
+		if policyIDs.Len() > 0 {
+			return fmt.Errorf("%q can only be set with %q %q", userResourcePolicyIDsAttrKey, userResourcePermissionStrategyAttrKey, users.PermissionStrategyPolicyBased)
+		}
+	}
+
+	return nil
+}
+
+// retrieveGPGKey resolves pgp_key, tests replace it to stub keybase
+var retrieveGPGKey = encryption.RetrieveGPGKey
+
+func validatePGPKey(i interface{}, k string) ([]string, []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %q to be string", k)}
+	}
+
+	if strings.HasPrefix(v, "keybase:") {
+		if strings.TrimPrefix(v, "keybase:") == "" {
+			return nil, []error{fmt.Errorf("%q is missing the keybase username", k)}
+		}
+		return nil, nil
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(v); err != nil {
+		return nil, []error{fmt.Errorf("%q must be a base64 encoded PGP public key or keybase:<username>: %v", k, err)}
+	}
+	return nil, nil
+}
+
+// checkProgrammaticUserPGPKey makes sure pgp_key can encrypt. An unusable
+// key must fail before a user is created, its token couldn't be stored
+// otherwise.
+func checkProgrammaticUserPGPKey(d *schema.ResourceData) error {
+	pgpKey := d.Get(userResourcePGPKeyAttrKey).(string)
+	if pgpKey == "" {
+		return nil
+	}
+
+	encryptionKey, err := retrieveGPGKey(pgpKey)
 	if err != nil {
-		d.SetId("")
 		return err
 	}
+	_, _, err = encryption.EncryptValue(encryptionKey, "", "access token")
+	return err
+}
 
-	log.Println(obj)
+// setProgrammaticUserToken stores a newly issued access token, encrypted
+// when pgp_key is set
+func setProgrammaticUserToken(d *schema.ResourceData, token string) error {
+	pgpKey := d.Get(userResourcePGPKeyAttrKey).(string)
+	if pgpKey == "" {
+		return d.Set(userResourceAccessTokenAttrKey, token)
+	}
 
-	actualName := strings.ToLower(obj.CoreUser.FirstName)
+	encryptionKey, err := retrieveGPGKey(pgpKey)
+	if err != nil {
+		return err
+	}
 
-	if actualName == d.Id() {
-		d.SetId(actualName)
-		return d.Set(userResourceAccountIDAttrKey, obj.AccountID)
+	fingerprint, encrypted, err := encryption.EncryptValue(encryptionKey, token, "access token")
+	if err != nil {
+		return err
 	}
 
-	d.SetId("")
-	return nil
+	d.Set(userResourceAccessTokenAttrKey, "")
+	d.Set(userResourceEncryptedAccessTokenAttrKey, encrypted)
+	return d.Set(userResourceKeyFingerprintAttrKey, fingerprint)
+}
+
+func expandProgrammaticUserPermissions(d *schema.ResourceData) users.Permissions {
+	strategy := d.Get(userResourcePermissionStrategyAttrKey).(string)
+	if strategy == users.PermissionStrategyPolicyBased {
+		var policyIDs []int
+		for _, id := range d.Get(userResourcePolicyIDsAttrKey).(*schema.Set).List() {
+			policyIDs = append(policyIDs, id.(int))
+		}
+		sort.Ints(policyIDs)
+
+		return users.Permissions{Strategy: strategy, PolicyIDs: policyIDs}
+	}
+
+	return users.Permissions{
+		Strategy:    strategy,
+		AccountRole: accountRoles[d.Get(userResourceAccountRoleAttrKey).(string)],
+	}
+}
+
+// programmaticUserID builds the resource ID from the account of the user
+func programmaticUserID(accountID string, userID int) string {
+	return fmt.Sprintf("%s/%d", accountID, userID)
+}
+
+// parseProgrammaticUserID splits an ID built by programmaticUserID. State
+// upgraded from version 0 carries the user name instead of the numeric ID
+// until the next read.
+func parseProgrammaticUserID(id string) (accountID, userID string, err error) {
+	parts := strings.SplitN(id, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("unexpected format of ID %q, expected <account_id>/<user_id>", id)
+	}
+	return parts[0], parts[1], nil
+}
+
+func resourceProgrammaticUserRead(d *schema.ResourceData, m interface{}) error {
+	usersService := m.(*Meta).usersService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutRead))
+	defer cancel()
+
+	accountID, userID, err := parseProgrammaticUserID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	id, convErr := strconv.Atoi(userID)
+	if convErr != nil {
+		// Version 0 state only knows the user by name, the
+		// numeric ID replaces it here
+		userName := strings.ToLower(d.Get(userResourceNameAttrKey).(string))
+		obj, err := usersService.GetWithContext(ctx, userName, accountID)
+		if err != nil {
+			return readProgrammaticUserErr(d, err)
+		}
+		id = obj.CoreUser.ID
+	}
+
+	obj, err := usersService.GetByIDWithContext(ctx, accountID, id)
+	if err != nil {
+		return readProgrammaticUserErr(d, err)
+	}
+
+	d.SetId(programmaticUserID(accountID, obj.CoreUser.ID))
+	d.Set(userResourceNameAttrKey, obj.CoreUser.FirstName)
+
+	// Neither the role nor the policies are documented parts of the
+	// account user mapping, changes made outside of Terraform can't
+	// be detected
+	if obj.PermissionStrategy != "" {
+		d.Set(userResourcePermissionStrategyAttrKey, obj.PermissionStrategy)
+	}
+
+	return d.Set(userResourceAccountIDAttrKey, accountID)
+}
+
+// readProgrammaticUserErr drops the user from state when it's gone.
+// Other lookup failures must keep it, it would be orphaned otherwise.
+func readProgrammaticUserErr(d *schema.ResourceData, err error) error {
+	if users.IsUserNotFoundErr(err) {
+		log.Printf("[WARN] Removing %s from state: %v", d.Id(), err)
+		d.SetId("")
+		return nil
+	}
+	return err
 }
 
-// func resourceProgrammaticUserUpdate(d *schema.ResourceData, m interface{}) error {
-// }
 func resourceProgrammaticUserCreate(d *schema.ResourceData, m interface{}) error {
 	usersService := m.(*Meta).usersService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutCreate))
+	defer cancel()
+
+	if err := checkProgrammaticUserPGPKey(d); err != nil {
+		return err
+	}
+
+	if err := createProgrammaticUser(ctx, d, usersService); err != nil {
+		return err
+	}
 
+	return resourceProgrammaticUserRead(d, m)
+}
+
+// createProgrammaticUser creates the user and stores its ID and access token
+func createProgrammaticUser(ctx context.Context, d *schema.ResourceData, usersService *users.Service) error {
 	username := d.Get(userResourceNameAttrKey).(string)
 	description := d.Get(userResourceDescriptionAttrKey).(string)
 	accountID := d.Get(userResourceAccountIDAttrKey).(string)
 
-	log.Printf("IN_RESOURCE_CREATE: %v\n", accountID)
-	user, err := usersService.Create(username, description, accountID)
+	user, err := usersService.CreateWithPermissionsContext(ctx, username, description, accountID, expandProgrammaticUserPermissions(d))
 
 	if err != nil {
 		return err
 	}
 
-	d.SetId(strings.ToLower(user.CoreUser.FirstName))
-	if err := d.Set(userResourceAccessTokenAttrKey, user.AccessToken); err != nil {
-		return err
-	}
+	d.SetId(programmaticUserID(accountID, user.CoreUser.ID))
+	return setProgrammaticUserToken(d, user.AccessToken)
+}
 
-	if err := d.Set(userResourceAccountIDAttrKey, accountID); err != nil {
-		return err
+// resourceProgrammaticUserUpdate encrypts the plaintext access token in state
+// once pgp_key is added. The user and its token stay the same.
+func resourceProgrammaticUserUpdate(d *schema.ResourceData, m interface{}) error {
+	if d.HasChange(userResourcePGPKeyAttrKey) {
+		// The plan already clears the plaintext token
+		if token, _ := d.GetChange(userResourceAccessTokenAttrKey); token.(string) != "" {
+			if err := setProgrammaticUserToken(d, token.(string)); err != nil {
+				// Keeps pgp_key unset in state, so the token is
+				// encrypted on the next apply
+				d.Partial(true)
+				return err
+			}
+		}
 	}
 
 	return resourceProgrammaticUserRead(d, m)
@@ -98,7 +377,49 @@ func resourceProgrammaticUserCreate(d *schema.ResourceData, m interface{}) error
 
 func resourceProgrammaticUserDelete(d *schema.ResourceData, m interface{}) error {
 	usersService := m.(*Meta).usersService
-	username := d.Get(userResourceNameAttrKey).(string)
-	accountID := d.Get(userResourceAccountIDAttrKey).(string)
-	return usersService.Delete(username, accountID)
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutDelete))
+	defer cancel()
+
+	accountID, userID, err := parseProgrammaticUserID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	if id, convErr := strconv.Atoi(userID); convErr == nil {
+		err = usersService.DeleteByIDWithContext(ctx, accountID, id)
+	} else {
+		username := strings.ToLower(d.Get(userResourceNameAttrKey).(string))
+		err = usersService.DeleteWithContext(ctx, username, accountID)
+	}
+	if client.IsNotFound(err) || users.IsUserNotFoundErr(err) {
+		return nil
+	}
+	return err
+}
+
+// resourceProgrammaticUserImport accepts <account_id>/<user_id>. The access
+// token is only returned when a user is created, imported users have none.
+func resourceProgrammaticUserImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	usersService := m.(*Meta).usersService
+	ctx, cancel := m.(*Meta).requestContext(d.Timeout(schema.TimeoutRead))
+	defer cancel()
+
+	accountID, userID, err := parseProgrammaticUserID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected format of ID %q, user ID must be numeric", d.Id())
+	}
+
+	u, err := usersService.GetByIDWithContext(ctx, accountID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Set(userResourceAccountIDAttrKey, accountID)
+	d.Set(userResourceNameAttrKey, u.CoreUser.FirstName)
+	return []*schema.ResourceData{d}, nil
 }
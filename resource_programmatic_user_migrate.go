@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// resourceProgrammaticUserV0 is the schema of version 0, where the ID
+// was the lowercased user name
+func resourceProgrammaticUserV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			userResourceAccountIDAttrKey: &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			userResourceNameAttrKey: &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			userResourceDescriptionAttrKey: &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				Default:  "",
+			},
+			userResourceAccessTokenAttrKey: &schema.Schema{
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+		},
+	}
+}
+
+// resourceProgrammaticUserStateUpgradeV0 prefixes the name-only ID with the
+// account ID. The numeric user ID isn't known without calling the API, so
+// the next read looks the user up by name and stores it.
+func resourceProgrammaticUserStateUpgradeV0(rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	id, _ := rawState["id"].(string)
+	accountID, _ := rawState[userResourceAccountIDAttrKey].(string)
+
+	if id == "" || accountID == "" {
+		return nil, fmt.Errorf("cannot upgrade programmatic user state without id and %s", userResourceAccountIDAttrKey)
+	}
+
+	rawState["id"] = accountID + "/" + id
+
+	log.Printf("Upgraded programmatic user ID from %q to %q\n", id, rawState["id"])
+
+	return rawState, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func TestResourceProgrammaticUserStateUpgradeV0(t *testing.T) {
+	v0 := map[string]interface{}{
+		"id":          "ci-robot",
+		"account_id":  "act-12345678",
+		"name":        "ci-robot",
+		"description": "",
+	}
+	expected := map[string]interface{}{
+		"id":          "act-12345678/ci-robot",
+		"account_id":  "act-12345678",
+		"name":        "ci-robot",
+		"description": "",
+	}
+
+	actual, err := resourceProgrammaticUserStateUpgradeV0(v0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+
+	if _, err := resourceProgrammaticUserStateUpgradeV0(map[string]interface{}{"id": "ci-robot"}, nil); err == nil {
+		t.Fatal("expected an error for state without account_id")
+	}
+}
+
+func TestResourceProgrammaticUserReadUpgradedState(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	m := testMeta(t, srv)
+	account := srv.AddAccount("legacy")
+	user, err := m.usersService.Create("ci-robot", "", account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceProgrammaticUser().Schema, map[string]interface{}{
+		"account_id": account.ID,
+		"name":       "ci-robot",
+	})
+	d.SetId(account.ID + "/ci-robot")
+
+	if err := resourceProgrammaticUserRead(d, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := account.ID + "/" + strconv.Itoa(user.CoreUser.ID); d.Id() != expected {
+		t.Fatalf("expected ID %q, got %q", expected, d.Id())
+	}
+}
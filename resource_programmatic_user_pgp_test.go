@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+// testPGPPublicKey is an encryption-only RSA key generated for the tests,
+// exported with gpg --export | base64
+const testPGPPublicKey = "mQENBGrUPAQBCACqk9BRvEMlD+FQPlyOS4wwqp3KsiyfwiAFhqGI5QEHAJjCLR3+27+Con2mKo+xZEME2KQAU6dlWsRFg/VvKq1h6Tkftk7h8I+LBgZ56iDGPMqPws1/HTaYv2VBBLhEmMZz9Ovuv6UWEJkbxcOajKdVeEufdWJ84YqbDMgYCmb3vA42eYwKyeh9RXs7mFh2AoET04OKDsBdEbyqes+x56VDsidJnPa8ION6mcjtYpetxoYDg/eNuVGGae6RmpnxYq+7Rn3crZ4ifP3C8RPSOmWnMtVP8humCJv+l7EQxDps1+SPOKZ+NazffUP9IcSlKxmUEFAn4T4eBDt2udn82lOnABEBAAG0IVRlcnJhZm9ybSBUZXN0IDx0ZXN0QGV4YW1wbGUuY29tPokBTgQTAQoAOBYhBLYUC/v1itN5jZyqCE61mdmyMN2sBQJq1DwEAhsNBQsJCAcCBhUKCQgLAgQWAgMBAh4BAheAAAoJEE61mdmyMN2slpYIAKLBppfNAnCHJxSFrxuM3NfikL71YF7TiruJZSKAcWC7K51M9gDJkkv7jwfcv9MbokDpNnjcLulFFVFqxRTV83TL7+ism4zgI2vt4a6E9kCZYnVrXCqMOzDm11a/tk79cq+m+7epQWTE2SxGJ+hfSnqw1m2GdmoaP0Lk0xwpePPOnJ1YPWFTJkC5w9LmbjwmVXqHzyEvpU8+6xmeP0yiNp5P6KywTGc4ZhK6QeQ2aEE3Rgiq6jVkRoCFtsBi5Ck0FMNKfGtO7xfPZDyo/Ut2oEW8wmvumHSeFyI0/Q0Weao3mD/XL/S9MP1RUygkKPeOqKgfsZmV+RbkBsLJ09rmMxg="
+
+const testPGPKeyFingerprint = "b6140bfbf58ad3798d9caa084eb599d9b230ddac"
+
+func TestAccProgrammaticUser_pgpKey(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	// Stubs keybase, which would be queried over the network
+	defer func(orig func(string) (string, error)) { retrieveGPGKey = orig }(retrieveGPGKey)
+	retrieveGPGKey = func(pgpKey string) (string, error) {
+		if pgpKey == "keybase:terraform-test" {
+			return testPGPPublicKey, nil
+		}
+		return pgpKey, nil
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckProgrammaticUserDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProgrammaticUserPGPConfig(srv, `pgp_key = "keybase:terraform-test"`),
+				Check:  testAccCheckProgrammaticUserEncryptedToken(srv, "spotinstadmin_programmatic_user.test"),
+			},
+			{
+				// A different key needs a new token, the old one can't be
+				// decrypted to encrypt it again
+				Config:      testAccProgrammaticUserPGPConfig(srv, fmt.Sprintf("pgp_key = %q", testPGPPublicKey)),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`"pgp_key" can't be changed or removed once set`),
+			},
+			{
+				Config:      testAccProgrammaticUserPGPConfig(srv, ""),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`"pgp_key" can't be changed or removed once set`),
+			},
+		},
+	})
+}
+
+// Adding a key encrypts the token of the same user
+func TestAccProgrammaticUser_addPGPKey(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	var id string
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckProgrammaticUserDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProgrammaticUserPGPConfig(srv, ""),
+				Check: func(s *terraform.State) error {
+					id = s.RootModule().Resources["spotinstadmin_programmatic_user.test"].Primary.ID
+					return nil
+				},
+			},
+			{
+				Config: testAccProgrammaticUserPGPConfig(srv, fmt.Sprintf("pgp_key = %q", testPGPPublicKey)),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckProgrammaticUserEncryptedToken(srv, "spotinstadmin_programmatic_user.test"),
+					resource.TestCheckResourceAttrPtr("spotinstadmin_programmatic_user.test", "id", &id),
+				),
+			},
+		},
+	})
+}
+
+func TestAccProgrammaticUser_unusablePGPKeyKeepsUser(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	defer func(orig func(string) (string, error)) { retrieveGPGKey = orig }(retrieveGPGKey)
+	retrieveGPGKey = func(pgpKey string) (string, error) {
+		return "", fmt.Errorf("no key found for %s", pgpKey)
+	}
+
+	var id, token string
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckProgrammaticUserDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProgrammaticUserPGPConfig(srv, ""),
+				Check: func(s *terraform.State) error {
+					rs := s.RootModule().Resources["spotinstadmin_programmatic_user.test"]
+					id, token = rs.Primary.ID, rs.Primary.Attributes["access_token"]
+					return nil
+				},
+			},
+			{
+				Config:      testAccProgrammaticUserPGPConfig(srv, `pgp_key = "keybase:nobody"`),
+				ExpectError: regexp.MustCompile("no key found for keybase:nobody"),
+			},
+			{
+				// The key wasn't stored, so the plaintext token is still
+				// in state and nothing is left to do
+				Config: testAccProgrammaticUserPGPConfig(srv, ""),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckProgrammaticUserExists(srv, "spotinstadmin_programmatic_user.test"),
+					resource.TestCheckResourceAttrPtr("spotinstadmin_programmatic_user.test", "id", &id),
+					resource.TestCheckResourceAttrPtr("spotinstadmin_programmatic_user.test", "access_token", &token),
+				),
+			},
+		},
+	})
+}
+
+// An unusable key must fail before the user is created, its token
+// couldn't be stored otherwise
+func TestAccProgrammaticUser_unusablePGPKey(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	defer func(orig func(string) (string, error)) { retrieveGPGKey = orig }(retrieveGPGKey)
+	retrieveGPGKey = func(pgpKey string) (string, error) {
+		return "", fmt.Errorf("no key found for %s", pgpKey)
+	}
+
+	accountConfig := testAccAccountConfig(srv, "user-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1")
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckProgrammaticUserDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccProgrammaticUserPGPConfig(srv, `pgp_key = "keybase:nobody"`),
+				ExpectError: regexp.MustCompile("no key found for keybase:nobody"),
+			},
+			{
+				Config: accountConfig,
+				Check: func(s *terraform.State) error {
+					accountID := s.RootModule().Resources["spotinstadmin_account.test"].Primary.ID
+					if users := srv.Users(accountID); len(users) != 0 {
+						return fmt.Errorf("Expected no user to be created, got %v", users)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestAccProgrammaticUser_invalidPGPKey(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testProviders(),
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccProgrammaticUserPGPConfig(srv, `pgp_key = "keybase:"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`"pgp_key" is missing the keybase username`),
+			},
+			{
+				Config:      testAccProgrammaticUserPGPConfig(srv, `pgp_key = "not a key"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`"pgp_key" must be a base64 encoded PGP public key`),
+			},
+		},
+	})
+}
+
+// testAccCheckProgrammaticUserEncryptedToken checks only the encrypted
+// access token made it to state
+func testAccCheckProgrammaticUserEncryptedToken(srv *fakespotinst.Server, n string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+		u, ok := testAccProgrammaticUser(srv, rs.Primary.ID)
+		if !ok {
+			return fmt.Errorf("User %s does not exist in the API", rs.Primary.ID)
+		}
+
+		attrs := rs.Primary.Attributes
+		if attrs["access_token"] != "" {
+			return fmt.Errorf("User %s has a plaintext access token in state", rs.Primary.ID)
+		}
+		if attrs["encrypted_access_token"] == "" || attrs["encrypted_access_token"] == u.Token {
+			return fmt.Errorf("User %s has no encrypted access token in state", rs.Primary.ID)
+		}
+		if attrs["key_fingerprint"] != testPGPKeyFingerprint {
+			return fmt.Errorf("User %s has key fingerprint %q, expected %q", rs.Primary.ID, attrs["key_fingerprint"], testPGPKeyFingerprint)
+		}
+		return nil
+	}
+}
+
+func testAccProgrammaticUserPGPConfig(srv *fakespotinst.Server, pgpKey string) string {
+	return testAccAccountConfig(srv, "user-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1") + fmt.Sprintf(`
+resource "spotinstadmin_programmatic_user" "test" {
+  name       = "ci-robot"
+  account_id = spotinstadmin_account.test.id
+  %s
+}
+`, pgpKey)
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccProgrammaticUser_basic(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckProgrammaticUserDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProgrammaticUserConfig(srv, "ci-robot"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckProgrammaticUserExists(srv, "spotinstadmin_programmatic_user.test"),
+					resource.TestCheckResourceAttr("spotinstadmin_programmatic_user.test", "name", "ci-robot"),
+					resource.TestCheckResourceAttrPair("spotinstadmin_programmatic_user.test", "account_id", "spotinstadmin_account.test", "id"),
+					resource.TestCheckResourceAttrSet("spotinstadmin_programmatic_user.test", "access_token"),
+					resource.TestMatchResourceAttr("spotinstadmin_programmatic_user.test", "id", regexp.MustCompile(`^act-[0-9a-f]+/[0-9]+$`)),
+				),
+			},
+			{
+				Config:            testAccProgrammaticUserConfig(srv, "ci-robot"),
+				ResourceName:      "spotinstadmin_programmatic_user.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				// Neither is returned by the account user mapping, the
+				// unknown account_role doesn't replace the user
+				ImportStateVerifyIgnore: []string{"access_token", "account_role"},
+			},
+		},
+	})
+}
+
+func TestAccProgrammaticUser_description(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckProgrammaticUserDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProgrammaticUserConfig(srv, "ci-robot") + `
+resource "spotinstadmin_programmatic_user" "described" {
+  name        = "described"
+  account_id  = spotinstadmin_account.test.id
+  description = "Managed by Terraform"
+}
+`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("spotinstadmin_programmatic_user.described", "description", "Managed by Terraform"),
+					func(s *terraform.State) error {
+						u, _ := testAccProgrammaticUser(srv, s.RootModule().Resources["spotinstadmin_programmatic_user.described"].Primary.ID)
+						if u.Description != "Managed by Terraform" {
+							return fmt.Errorf("Expected the user to be created with the description, got %q", u.Description)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+// The description and account role of imported users are unknown, setting
+// them in the configuration must not replace the users
+func TestProgrammaticUserUnknownValueDiff(t *testing.T) {
+	cases := map[string]struct {
+		attr        string
+		id          string
+		old         string
+		new         string
+		expectDiff  bool
+		expectForce bool
+	}{
+		"new user": {
+			attr:        "description",
+			new:         "Managed by Terraform",
+			expectDiff:  true,
+			expectForce: true,
+		},
+		"imported user description": {
+			attr: "description",
+			id:   "act-12345678/1",
+			new:  "Managed by Terraform",
+		},
+		"imported user role": {
+			attr: "account_role",
+			id:   "act-12345678/1",
+			new:  "viewer",
+		},
+		"changed description": {
+			attr:        "description",
+			id:          "act-12345678/1",
+			old:         "Managed by hand",
+			new:         "Managed by Terraform",
+			expectDiff:  true,
+			expectForce: true,
+		},
+		"changed role": {
+			attr:        "account_role",
+			id:          "act-12345678/1",
+			old:         "editor",
+			new:         "viewer",
+			expectDiff:  true,
+			expectForce: true,
+		},
+		"unchanged": {
+			attr: "description",
+			id:   "act-12345678/1",
+			old:  "Managed by Terraform",
+			new:  "Managed by Terraform",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var state *terraform.InstanceState
+			if tc.id != "" {
+				state = &terraform.InstanceState{
+					ID: tc.id,
+					Attributes: map[string]string{
+						"id":                  tc.id,
+						"account_id":          "act-12345678",
+						"name":                "ci-robot",
+						"permission_strategy": "ROLE_BASED",
+						tc.attr:               tc.old,
+					},
+				}
+			}
+			config := terraform.NewResourceConfigRaw(map[string]interface{}{
+				"account_id": "act-12345678",
+				"name":       "ci-robot",
+				tc.attr:      tc.new,
+			})
+
+			diff, err := resourceProgrammaticUser().Diff(state, config, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var attr *terraform.ResourceAttrDiff
+			if diff != nil {
+				attr = diff.Attributes[tc.attr]
+			}
+			if tc.expectDiff != (attr != nil) {
+				t.Fatalf("expected a %s diff: %t, got %#v", tc.attr, tc.expectDiff, attr)
+			}
+			if attr != nil && attr.RequiresNew != tc.expectForce {
+				t.Fatalf("expected %s to force replacement: %t", tc.attr, tc.expectForce)
+			}
+		})
+	}
+}
+
+// Users are tracked by ID, users sharing the name or differing only in
+// case must not be mixed up on read or delete
+func TestAccProgrammaticUser_duplicateName(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	var accountID string
+	var other fakespotinst.User
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckProgrammaticUserDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				// Only creates the account the users are mapped to
+				Config: testAccAccountConfig(srv, "user-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				Check: func(s *terraform.State) error {
+					accountID = s.RootModule().Resources["spotinstadmin_account.test"].Primary.ID
+					other = srv.AddUser(accountID, "CI-Robot")
+					return nil
+				},
+			},
+			{
+				Config: testAccProgrammaticUserConfig(srv, "CI-Robot"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckProgrammaticUserExists(srv, "spotinstadmin_programmatic_user.test"),
+					resource.TestCheckResourceAttr("spotinstadmin_programmatic_user.test", "name", "CI-Robot"),
+					func(s *terraform.State) error {
+						if id := s.RootModule().Resources["spotinstadmin_programmatic_user.test"].Primary.ID; id == programmaticUserID(accountID, other.ID) {
+							return fmt.Errorf("Resource picked up user %s created outside of Terraform", id)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				// Destroys only the programmatic user
+				Config: testAccAccountConfig(srv, "user-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1"),
+				Check: func(*terraform.State) error {
+					if _, ok := srv.User(other.ID); !ok {
+						return fmt.Errorf("User %d created outside of Terraform was deleted", other.ID)
+					}
+					if n := len(srv.Users(accountID)); n != 1 {
+						return fmt.Errorf("Expected only the user created outside of Terraform to be left, got %d users", n)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestAccProgrammaticUser_permissions(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testProviders(),
+		CheckDestroy: testAccCheckProgrammaticUserDestroy(srv),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProgrammaticUserPermissionsConfig(srv, "ci-viewer", `account_role = "viewer"`),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckProgrammaticUserExists(srv, "spotinstadmin_programmatic_user.test"),
+					testAccCheckProgrammaticUserPermissions(srv, "spotinstadmin_programmatic_user.test", "ROLE_BASED", 1, nil),
+					resource.TestCheckResourceAttr("spotinstadmin_programmatic_user.test", "permission_strategy", "ROLE_BASED"),
+					resource.TestCheckResourceAttr("spotinstadmin_programmatic_user.test", "account_role", "viewer"),
+				),
+			},
+			{
+				Config: testAccProgrammaticUserPermissionsConfig(srv, "ci-viewer", `
+  permission_strategy = "POLICY_BASED"
+  policy_ids          = [42, 7]
+`),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckProgrammaticUserExists(srv, "spotinstadmin_programmatic_user.test"),
+					testAccCheckProgrammaticUserPermissions(srv, "spotinstadmin_programmatic_user.test", "POLICY_BASED", 0, []int{7, 42}),
+					resource.TestCheckResourceAttr("spotinstadmin_programmatic_user.test", "permission_strategy", "POLICY_BASED"),
+					resource.TestCheckResourceAttr("spotinstadmin_programmatic_user.test", "policy_ids.#", "2"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccProgrammaticUser_invalidPermissions(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	cases := map[string]struct {
+		permissions string
+		expectErr   string
+	}{
+		"unknown role":        {`account_role = "owner"`, `expected account_role to be one of`},
+		"unknown strategy":    {`permission_strategy = "ACL"`, `expected permission_strategy to be one of`},
+		"policies on role":    {`policy_ids = [1]`, `"policy_ids" can only be set with "permission_strategy" "POLICY_BASED"`},
+		"policy without any":  {`permission_strategy = "POLICY_BASED"`, `"POLICY_BASED" requires at least one of "policy_ids"`},
+		"non-positive policy": {"permission_strategy = \"POLICY_BASED\"\n  policy_ids = [0]", `expected policy_ids.\d+ to be at least`},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			resource.UnitTest(t, resource.TestCase{
+				Providers: testProviders(),
+				Steps: []resource.TestStep{
+					{
+						Config:      testAccProgrammaticUserPermissionsConfig(srv, "ci-robot", tc.permissions),
+						PlanOnly:    true,
+						ExpectError: regexp.MustCompile(tc.expectErr),
+					},
+				},
+			})
+		})
+	}
+
+	if n := srv.RequestCount(http.MethodPost, "/setup/shared/ums/programmaticUser"); n != 0 {
+		t.Fatalf("expected no user to be created, got %d requests", n)
+	}
+}
+
+func testAccProgrammaticUserPermissionsConfig(srv *fakespotinst.Server, name, permissions string) string {
+	return testAccAccountConfig(srv, "user-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1") + fmt.Sprintf(`
+resource "spotinstadmin_programmatic_user" "test" {
+  name       = %q
+  account_id = spotinstadmin_account.test.id
+  %s
+}
+`, name, permissions)
+}
+
+func testAccCheckProgrammaticUserPermissions(srv *fakespotinst.Server, n, strategy string, role int, policyIDs []int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+		u, ok := testAccProgrammaticUser(srv, rs.Primary.ID)
+		if !ok {
+			return fmt.Errorf("User %s does not exist in the API", rs.Primary.ID)
+		}
+		mapping := u.Accounts[rs.Primary.Attributes["account_id"]]
+		if u.PermissionStrategy != strategy || mapping.AccountRole != role {
+			return fmt.Errorf("User %s has strategy %q and role %d, expected %q and %d", rs.Primary.ID, u.PermissionStrategy, mapping.AccountRole, strategy, role)
+		}
+		if fmt.Sprint(mapping.PolicyIDs) != fmt.Sprint(policyIDs) && len(mapping.PolicyIDs)+len(policyIDs) > 0 {
+			return fmt.Errorf("User %s has policies %v, expected %v", rs.Primary.ID, mapping.PolicyIDs, policyIDs)
+		}
+		return nil
+	}
+}
+
+func testAccProgrammaticUserConfig(srv *fakespotinst.Server, name string) string {
+	return testAccAccountConfig(srv, "user-account", "arn:aws:iam::123456789012:role/spotinst", "ext-1") + fmt.Sprintf(`
+resource "spotinstadmin_programmatic_user" "test" {
+  name       = %q
+  account_id = spotinstadmin_account.test.id
+}
+`, name)
+}
+
+func testAccCheckProgrammaticUserExists(srv *fakespotinst.Server, n string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+		u, ok := testAccProgrammaticUser(srv, rs.Primary.ID)
+		if !ok {
+			return fmt.Errorf("User %s does not exist in the API", rs.Primary.ID)
+		}
+		if u.Name != rs.Primary.Attributes["name"] {
+			return fmt.Errorf("User %s is named %q in the API, %q in state", rs.Primary.ID, u.Name, rs.Primary.Attributes["name"])
+		}
+		if u.Token != rs.Primary.Attributes["access_token"] {
+			return fmt.Errorf("User %s has access token %q in state, API issued %q", u.Name, rs.Primary.Attributes["access_token"], u.Token)
+		}
+		return nil
+	}
+}
+
+func testAccCheckProgrammaticUserDestroy(srv *fakespotinst.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != programmaticUserResourceName {
+				continue
+			}
+			if u, ok := testAccProgrammaticUser(srv, rs.Primary.ID); ok {
+				return fmt.Errorf("User %s still exists as %s", u.Name, strconv.Itoa(u.ID))
+			}
+		}
+		return nil
+	}
+}
+
+// testAccProgrammaticUser looks up the user behind a resource ID in the fake
+func testAccProgrammaticUser(srv *fakespotinst.Server, id string) (fakespotinst.User, bool) {
+	accountID, userID, err := parseProgrammaticUserID(id)
+	if err != nil {
+		return fakespotinst.User{}, false
+	}
+	n, err := strconv.Atoi(userID)
+	if err != nil {
+		return fakespotinst.User{}, false
+	}
+	u, ok := srv.User(n)
+	_, mapped := u.Accounts[accountID]
+	return u, ok && mapped
+}
+
+func TestResourceProgrammaticUserRead(t *testing.T) {
+	cases := map[string]struct {
+		setup       func(t *testing.T, srv *fakespotinst.Server, accountID string, userID int)
+		expectErr   bool
+		expectState bool
+	}{
+		"exists": {
+			setup:       func(*testing.T, *fakespotinst.Server, string, int) {},
+			expectState: true,
+		},
+		"deleted": {
+			setup: func(t *testing.T, srv *fakespotinst.Server, accountID string, userID int) {
+				if err := testMeta(t, srv).usersService.DeleteByID(accountID, userID); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		"server error": {
+			setup: func(_ *testing.T, srv *fakespotinst.Server, _ string, _ int) {
+				srv.InjectFault(fakespotinst.Fault{Path: "/setup/shared/accountUserMapping", StatusCode: http.StatusInternalServerError})
+			},
+			expectErr:   true,
+			expectState: true,
+		},
+		"unauthorized": {
+			setup: func(_ *testing.T, srv *fakespotinst.Server, _ string, _ int) {
+				srv.InjectFault(fakespotinst.Fault{Path: "/setup/shared/accountUserMapping", StatusCode: http.StatusUnauthorized})
+			},
+			expectErr:   true,
+			expectState: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := fakespotinst.New()
+			defer srv.Close()
+
+			account := srv.AddAccount("a")
+			user, err := testMeta(t, srv).usersService.Create("ci-robot", "", account.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tc.setup(t, srv, account.ID, user.CoreUser.ID)
+
+			// Reads start with an empty cache, as in a new Terraform run
+			m := testMeta(t, srv)
+
+			d := schema.TestResourceDataRaw(t, resourceProgrammaticUser().Schema, map[string]interface{}{
+				"account_id": account.ID,
+				"name":       "ci-robot",
+			})
+			id := programmaticUserID(account.ID, user.CoreUser.ID)
+			d.SetId(id)
+
+			err = resourceProgrammaticUserRead(d, m)
+			if tc.expectErr && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tc.expectErr && err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.expectState && d.Id() != id {
+				t.Fatalf("expected ID %q to be kept, got %q", id, d.Id())
+			}
+			if !tc.expectState && d.Id() != "" {
+				t.Fatalf("expected the resource to be removed from state, got ID %q", d.Id())
+			}
+		})
+	}
+}
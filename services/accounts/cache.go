@@ -0,0 +1,31 @@
+package accounts
+
+import (
+	"context"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/services/internal/cache"
+)
+
+// listCache keeps the account list for the lifetime of the service, which is
+// a single Terraform run. Concurrent lookups share one in-flight request.
+type listCache struct {
+	cache cache.Cache
+}
+
+// listCacheKey is the only key, there is one list per organization
+const listCacheKey = ""
+
+// get returns the cached list or calls fetch, sharing the call with
+// concurrent callers. fromCache reports whether the list was already cached.
+func (c *listCache) get(ctx context.Context, fetch func(context.Context) ([]*Account, error)) (accounts []*Account, fromCache bool, err error) {
+	v, fromCache, err := c.cache.Get(ctx, listCacheKey, func(ctx context.Context, _ string) (interface{}, error) {
+		return fetch(ctx)
+	})
+	accounts, _ = v.([]*Account)
+	return accounts, fromCache, err
+}
+
+// invalidate drops the cached list, the next lookup goes to the API
+func (c *listCache) invalidate() {
+	c.cache.Invalidate(listCacheKey)
+}
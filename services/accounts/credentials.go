@@ -0,0 +1,224 @@
+package accounts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client/common"
+)
+
+// AWSCredentials are the AWS credentials connected to an account.
+// The external ID is write-only and never returned by the API.
+type AWSCredentials struct {
+	IAMRole string `json:"iamRole"`
+}
+
+// GetAWSCredentials returns the AWS credentials configured for an account
+func (as *Service) GetAWSCredentials(accountID string) (*AWSCredentials, error) {
+	return as.GetAWSCredentialsWithContext(context.Background(), accountID)
+}
+
+// GetAWSCredentialsWithContext is like GetAWSCredentials but the request is bound to ctx
+func (as *Service) GetAWSCredentialsWithContext(ctx context.Context, accountID string) (*AWSCredentials, error) {
+	req, err := as.httpClient.NewRequestWithContext(ctx, http.MethodGet, "/setup/credentials/aws", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q, _ := url.ParseQuery(req.URL.RawQuery)
+	q.Add("accountId", accountID)
+	req.URL.RawQuery = q.Encode()
+
+	var r common.Response
+
+	_, err = as.httpClient.Do(req, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Response.Items) == 0 {
+		return nil, fmt.Errorf("no AWS credentials returned for account %s", accountID)
+	}
+
+	var creds AWSCredentials
+
+	err = json.Unmarshal(r.Response.Items[0], &creds)
+	if err != nil {
+		return nil, err
+	}
+
+	return &creds, nil
+}
+
+// GenerateAWSExternalID asks Spotinst for an external ID to put into the
+// trust policy of the IAM role the account will assume
+func (as *Service) GenerateAWSExternalID(accountID string) (string, error) {
+	return as.GenerateAWSExternalIDWithContext(context.Background(), accountID)
+}
+
+// GenerateAWSExternalIDWithContext is like GenerateAWSExternalID but the request is bound to ctx
+func (as *Service) GenerateAWSExternalIDWithContext(ctx context.Context, accountID string) (string, error) {
+	req, err := as.httpClient.NewRequestWithContext(ctx, http.MethodPost, "/setup/credentials/aws/externalId", nil)
+	if err != nil {
+		return "", err
+	}
+
+	q, _ := url.ParseQuery(req.URL.RawQuery)
+	q.Add("accountId", accountID)
+	req.URL.RawQuery = q.Encode()
+
+	var r common.Response
+
+	_, err = as.httpClient.Do(req, &r)
+	if err != nil {
+		return "", err
+	}
+
+	if len(r.Response.Items) == 0 {
+		return "", fmt.Errorf("no external ID returned for account %s", accountID)
+	}
+
+	var out struct {
+		ExternalID string `json:"externalId"`
+	}
+
+	err = json.Unmarshal(r.Response.Items[0], &out)
+	if err != nil {
+		return "", err
+	}
+
+	return out.ExternalID, nil
+}
+
+// SetAWSCredentials connects an account to AWS through a cross-account role,
+// calling it again replaces the credentials
+func (as *Service) SetAWSCredentials(accountID, iamRole, externalID string) error {
+	return as.SetAWSCredentialsWithContext(context.Background(), accountID, iamRole, externalID)
+}
+
+// SetAWSCredentialsWithContext is like SetAWSCredentials but the request is bound to ctx
+func (as *Service) SetAWSCredentialsWithContext(ctx context.Context, accountID, iamRole, externalID string) error {
+
+	body := map[string]map[string]string{
+		"credentials": {"iamRole": iamRole, "externalId": externalID},
+	}
+
+	req, err := as.httpClient.NewRequestWithContext(ctx, http.MethodPost, "/setup/credentials/aws", &body)
+	if err != nil {
+		return err
+	}
+
+	q, _ := url.ParseQuery(req.URL.RawQuery)
+
+	q.Add("accountId", accountID)
+
+	req.URL.RawQuery = q.Encode()
+
+	// Setting the same credentials twice is harmless, so the request
+	// can be retried on server errors
+	req = client.Idempotent(req)
+
+	var r common.Response
+
+	resp, err := as.httpClient.Do(req, &r)
+	as.cache.invalidate()
+	if err != nil {
+		return fmt.Errorf("failed setting up cloud credentials, %w", err)
+	}
+
+	if len(r.Response.Errors) > 0 {
+		return fmt.Errorf("failed setting up cloud credentials, %v", client.NewAPIError(resp.StatusCode, &r))
+	}
+
+	return nil
+}
+
+// AzureCredentials connect an account to an Azure subscription
+// through a service principal
+type AzureCredentials struct {
+	TenantID       string `json:"tenantId"`
+	ClientID       string `json:"clientId"`
+	ClientSecret   string `json:"clientSecret"`
+	SubscriptionID string `json:"subscriptionId"`
+}
+
+// SetAzureCredentials connects an account to Azure, calling it
+// again replaces the credentials
+func (as *Service) SetAzureCredentials(accountID string, creds *AzureCredentials) error {
+	return as.SetAzureCredentialsWithContext(context.Background(), accountID, creds)
+}
+
+// SetAzureCredentialsWithContext is like SetAzureCredentials but the request is bound to ctx
+func (as *Service) SetAzureCredentialsWithContext(ctx context.Context, accountID string, creds *AzureCredentials) error {
+	req, err := as.httpClient.NewRequestWithContext(ctx, http.MethodPost, "/azure/setup/credentials", creds)
+	if err != nil {
+		return err
+	}
+
+	q, _ := url.ParseQuery(req.URL.RawQuery)
+	q.Add("accountId", accountID)
+	req.URL.RawQuery = q.Encode()
+
+	req = client.Idempotent(req)
+
+	var r common.Response
+
+	resp, err := as.httpClient.Do(req, &r)
+	as.cache.invalidate()
+	if err != nil {
+		return fmt.Errorf("failed setting up Azure credentials, %w", err)
+	}
+
+	if len(r.Response.Errors) > 0 {
+		return fmt.Errorf("failed setting up Azure credentials, %v", client.NewAPIError(resp.StatusCode, &r))
+	}
+
+	return nil
+}
+
+// SetGCPCredentials connects an account to GCP with the content of a service
+// account key file, calling it again replaces the credentials
+func (as *Service) SetGCPCredentials(accountID, serviceAccountJSON string) error {
+	return as.SetGCPCredentialsWithContext(context.Background(), accountID, serviceAccountJSON)
+}
+
+// SetGCPCredentialsWithContext is like SetGCPCredentials but the request is bound to ctx
+func (as *Service) SetGCPCredentialsWithContext(ctx context.Context, accountID, serviceAccountJSON string) error {
+	if !json.Valid([]byte(serviceAccountJSON)) {
+		return errors.New("GCP service account key is not valid JSON")
+	}
+
+	body := map[string]json.RawMessage{
+		"serviceAccount": json.RawMessage(serviceAccountJSON),
+	}
+
+	req, err := as.httpClient.NewRequestWithContext(ctx, http.MethodPost, "/gcp/setup/credentials", &body)
+	if err != nil {
+		return err
+	}
+
+	q, _ := url.ParseQuery(req.URL.RawQuery)
+	q.Add("accountId", accountID)
+	req.URL.RawQuery = q.Encode()
+
+	req = client.Idempotent(req)
+
+	var r common.Response
+
+	resp, err := as.httpClient.Do(req, &r)
+	as.cache.invalidate()
+	if err != nil {
+		return fmt.Errorf("failed setting up GCP credentials, %w", err)
+	}
+
+	if len(r.Response.Errors) > 0 {
+		return fmt.Errorf("failed setting up GCP credentials, %v", client.NewAPIError(resp.StatusCode, &r))
+	}
+
+	return nil
+}
@@ -1,13 +1,13 @@
 package accounts
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"net/url"
-	"time"
+	"strings"
 
 	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
 	"github.com/cnicolov/terraform-provider-spotinstadmin/client/common"
@@ -21,14 +21,20 @@ const (
 // Service is a client for creating accounts
 type Service struct {
 	httpClient *client.Client
+	cache      listCache
 }
 
-// New creates new accounts service client
-func New(token string) *Service {
+// New creates new accounts service client. The API endpoint can
+// be overridden with client.WithBaseURL
+func New(token string, opts ...client.Option) (*Service, error) {
 	log.Println("Initializing accounts service")
-	return &Service{
-		httpClient: client.New(accountServiceBaseURL, token),
+	httpClient, err := client.New(accountServiceBaseURL, token, opts...)
+	if err != nil {
+		return nil, err
 	}
+	return &Service{
+		httpClient: httpClient,
+	}, nil
 }
 
 // Account represesnts Spotinst account in API
@@ -37,27 +43,62 @@ type Account struct {
 	Name               string `json:"name"`
 	OrganizationID     string `json:"organizationId"`
 	ProviderExternalID string `json:"providerExternalId,omitempty"`
+	CloudProvider      string `json:"cloudProvider,omitempty"`
+	CreatedAt          string `json:"createdAt,omitempty"`
 }
 
 // AccountNotFoundError is raised when looking up account
 // fails because there's no such account in Spotinst:w
 type AccountNotFoundError struct {
-	AccountID string
+	AccountID   string
+	AccountName string
 }
 
 func (a *AccountNotFoundError) Error() string {
+	if a.AccountID == "" {
+		return fmt.Sprintf("Account named %q not found", a.AccountName)
+	}
 	return fmt.Sprintf("Account %s not found", a.AccountID)
 }
 
+// MultipleAccountsFoundError is raised when looking up account by
+// name matches more than one account
+type MultipleAccountsFoundError struct {
+	AccountName string
+	AccountIDs  []string
+}
+
+func (a *MultipleAccountsFoundError) Error() string {
+	return fmt.Sprintf("%d accounts named %q found: %s", len(a.AccountIDs), a.AccountName, strings.Join(a.AccountIDs, ", "))
+}
+
 // Create creates accoount in Spotinst
 func (as *Service) Create(name, iamRole, externalID string) (*Account, error) {
+	return as.CreateWithContext(context.Background(), name, iamRole, externalID)
+}
+
+// CreateWithContext is like Create but stops waiting for the API once ctx is done.
+// The account is deleted again when it doesn't become ready before the
+// deadline or when setting up its credentials fails.
+func (as *Service) CreateWithContext(ctx context.Context, name, iamRole, externalID string) (*Account, error) {
+	return as.CreateWithCredentialsContext(ctx, name, func(ctx context.Context, accountID string) error {
+		return as.SetAWSCredentialsWithContext(ctx, accountID, iamRole, externalID)
+	})
+}
+
+// ConnectFunc connects a freshly created account to a cloud provider
+type ConnectFunc func(ctx context.Context, accountID string) error
+
+// CreateWithCredentialsContext creates an account, waits until it's ready and
+// calls connect to set up its cloud credentials. The account is deleted again
+// when connect fails. A nil connect leaves the account without credentials.
+func (as *Service) CreateWithCredentialsContext(ctx context.Context, name string, connect ConnectFunc) (*Account, error) {
 
 	body := map[string]map[string]string{
 		"account": {"name": name},
 	}
 
-	log.Printf("Making request %v\n", body)
-	req, err := as.httpClient.NewRequest(http.MethodPost, "/setup/account", &body)
+	req, err := as.httpClient.NewRequestWithContext(ctx, http.MethodPost, "/setup/account", &body)
 
 	if err != nil {
 		return nil, err
@@ -65,6 +106,7 @@ func (as *Service) Create(name, iamRole, externalID string) (*Account, error) {
 
 	var v common.Response
 	_, err = as.httpClient.Do(req, &v)
+	as.cache.invalidate()
 	if err != nil {
 		return nil, err
 	}
@@ -73,64 +115,117 @@ func (as *Service) Create(name, iamRole, externalID string) (*Account, error) {
 	}
 	var account Account
 
-	fmt.Println(string(v.Response.Items[0]))
-
 	err = json.Unmarshal(v.Response.Items[0], &account)
 
 	if err != nil {
 		return nil, err
 	}
 
-	time.Sleep(time.Second * 5)
+	err = as.waitForAccountReady(ctx, account.ID)
 
-	err = as.setupCloudCredentials(account.ID, iamRole, externalID)
+	if err == nil && connect != nil {
+		err = connect(ctx, account.ID)
+	}
 
 	if err != nil {
-		_ = as.Delete(account.ID)
+		// ctx may be already cancelled here, the cleanup must still go out
+		_ = as.DeleteWithContext(context.Background(), account.ID)
 		return nil, err
 	}
 
 	return &account, nil
 }
 
-func (as *Service) setupCloudCredentials(accountID, iamRole, externalID string) error {
+// Get returns account by id
+func (as *Service) Get(id string) (*Account, error) {
+	return as.GetWithContext(context.Background(), id)
+}
+
+// GetWithContext is like Get but the lookup is bound to ctx
+func (as *Service) GetWithContext(ctx context.Context, id string) (*Account, error) {
+	log.Printf("Getting account %v\n", id)
 
-	body := map[string]map[string]string{
-		"credentials": {"iamRole": iamRole, "externalId": externalID},
+	accountList, fromCache, err := as.cache.get(ctx, as.fetchAccounts)
+	if err != nil {
+		return nil, err
 	}
 
-	req, err := as.httpClient.NewRequest(http.MethodPost, "/setup/credentials/aws", &body)
+	account, err := filterAccountByID(id, accountList)
 
-	q, _ := url.ParseQuery(req.URL.RawQuery)
+	// The account may have been created after the list was cached,
+	// so a miss is only trusted when the list is fresh
+	if IsAccountNotFoundErr(err) && fromCache {
+		as.cache.invalidate()
+		if accountList, _, err = as.cache.get(ctx, as.fetchAccounts); err != nil {
+			return nil, err
+		}
+		account, err = filterAccountByID(id, accountList)
+	}
 
-	q.Add("accountId", accountID)
+	return account, err
+}
+
+// GetByName returns the only account with exactly the given name
+func (as *Service) GetByName(name string) (*Account, error) {
+	return as.GetByNameWithContext(context.Background(), name)
+}
 
-	req.URL.RawQuery = q.Encode()
+// GetByNameWithContext is like GetByName but the lookup is bound to ctx
+func (as *Service) GetByNameWithContext(ctx context.Context, name string) (*Account, error) {
+	accountList, _, err := as.cache.get(ctx, as.fetchAccounts)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	log.Printf("%#v", req)
+	return filterAccountByName(name, accountList)
+}
 
-	var r common.Response
+// List returns all accounts of the organization. The list is cached
+// until an account is created, updated or deleted through the service.
+func (as *Service) List() ([]*Account, error) {
+	return as.ListWithContext(context.Background())
+}
 
-	_, err = as.httpClient.Do(req, &r)
+// ListWithContext is like List but the request is bound to ctx
+func (as *Service) ListWithContext(ctx context.Context) ([]*Account, error) {
+	accountList, _, err := as.cache.get(ctx, as.fetchAccounts)
+	if err != nil {
+		return nil, err
+	}
 
-	if len(r.Response.Errors) > 0 {
-		return fmt.Errorf("failed setting up cloud credentials, %#v", r.Response.Errors)
+	// Callers may reorder the list, the cached one must stay intact
+	return append([]*Account(nil), accountList...), nil
+}
+
+func (as *Service) fetchAccounts(ctx context.Context) ([]*Account, error) {
+	req, err := as.httpClient.NewRequestWithContext(ctx, http.MethodGet, "/setup/account", nil)
+
+	if err != nil {
+		return nil, err
 	}
 
-	log.Printf("%#v", r)
+	var r common.Response
 
-	return err
+	_, err = as.httpClient.Do(req, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	return accountsFromJSON(r)
 }
 
-// Get returns account by id
-func (as *Service) Get(id string) (*Account, error) {
-	log.Printf("Getting account %v\n", id)
+// Update renames an account
+func (as *Service) Update(id, name string) (*Account, error) {
+	return as.UpdateWithContext(context.Background(), id, name)
+}
 
-	req, err := as.httpClient.NewRequest(http.MethodGet, "/setup/account", nil)
+// UpdateWithContext is like Update but the request is bound to ctx
+func (as *Service) UpdateWithContext(ctx context.Context, id, name string) (*Account, error) {
+	body := map[string]map[string]string{
+		"account": {"name": name},
+	}
 
+	req, err := as.httpClient.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("/setup/account/%s", id), &body)
 	if err != nil {
 		return nil, err
 	}
@@ -138,24 +233,39 @@ func (as *Service) Get(id string) (*Account, error) {
 	var r common.Response
 
 	_, err = as.httpClient.Do(req, &r)
+	as.cache.invalidate()
 	if err != nil {
 		return nil, err
 	}
 
-	accountList, err := accountsFromJSON(r)
+	if len(r.Response.Items) == 0 {
+		return &Account{ID: id, Name: name}, nil
+	}
+
+	var account Account
 
+	err = json.Unmarshal(r.Response.Items[0], &account)
 	if err != nil {
 		return nil, err
 	}
 
-	return filterAccountByID(id, accountList)
+	return &account, nil
 }
 
 // Delete delets account by id
 func (as *Service) Delete(id string) error {
-	req, err := as.httpClient.NewRequest(http.MethodDelete, fmt.Sprintf("/setup/account/%s", id), nil)
+	return as.DeleteWithContext(context.Background(), id)
+}
+
+// DeleteWithContext is like Delete but the request is bound to ctx
+func (as *Service) DeleteWithContext(ctx context.Context, id string) error {
+	req, err := as.httpClient.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf("/setup/account/%s", id), nil)
+	if err != nil {
+		return err
+	}
 	v := make(map[string]interface{})
 	_, err = as.httpClient.Do(req, &v)
+	as.cache.invalidate()
 	return err
 }
 
@@ -178,6 +288,8 @@ func accountsFromJSON(r common.Response) ([]*Account, error) {
 		AccountID          string `json:"accountId"`
 		OrganizationID     string `json:"organizationId"`
 		ProviderExternalID string `json:"providerExternalId"`
+		CloudProvider      string `json:"cloudProvider"`
+		CreatedAt          string `json:"createdAt"`
 	}
 
 	for i, data := range r.Response.Items {
@@ -193,6 +305,8 @@ func accountsFromJSON(r common.Response) ([]*Account, error) {
 			Name:               acc.Name,
 			OrganizationID:     acc.OrganizationID,
 			ProviderExternalID: acc.ProviderExternalID,
+			CloudProvider:      acc.CloudProvider,
+			CreatedAt:          acc.CreatedAt,
 		}
 
 	}
@@ -208,3 +322,25 @@ func filterAccountByID(id string, accountList []*Account) (*Account, error) {
 	}
 	return nil, &AccountNotFoundError{AccountID: id}
 }
+
+func filterAccountByName(name string, accountList []*Account) (*Account, error) {
+	var matches []*Account
+	for _, a := range accountList {
+		if a.Name == name {
+			matches = append(matches, a)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, &AccountNotFoundError{AccountName: name}
+	case 1:
+		return matches[0], nil
+	}
+
+	ids := make([]string, len(matches))
+	for i, a := range matches {
+		ids[i] = a.ID
+	}
+	return nil, &MultipleAccountsFoundError{AccountName: name, AccountIDs: ids}
+}
@@ -0,0 +1,203 @@
+package accounts_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/services/accounts"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+)
+
+func newTestService(t *testing.T, srv *fakespotinst.Server) *accounts.Service {
+	svc, err := accounts.New(srv.APIToken, client.WithBaseURL(srv.URL), client.WithRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func listCount(srv *fakespotinst.Server) int {
+	return srv.RequestCount(http.MethodGet, "/setup/account")
+}
+
+func TestGetSharesConcurrentListCalls(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	var ids []string
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		ids = append(ids, srv.AddAccount(name).ID)
+	}
+
+	// Keep the first list call in flight long enough for all lookups to join it
+	srv.InjectFault(fakespotinst.Fault{Method: http.MethodGet, Path: "/setup/account", Latency: 100 * time.Millisecond, Times: 1})
+
+	svc := newTestService(t, srv)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(ids)*2)
+	for i := 0; i < 2; i++ {
+		for _, id := range ids {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				_, err := svc.Get(id)
+				errs <- err
+			}(id)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := listCount(srv); got != 1 {
+		t.Fatalf("expected one list call, got %d", got)
+	}
+}
+
+func TestGetSurvivesCancelledSharedCall(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	existing := srv.AddAccount("existing")
+
+	// The first list call outlives the context of the lookup making it
+	srv.InjectFault(fakespotinst.Fault{Method: http.MethodGet, Path: "/setup/account", Latency: 200 * time.Millisecond, Times: 1})
+
+	svc := newTestService(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := svc.GetWithContext(ctx, existing.ID)
+		leaderErr <- err
+	}()
+
+	// Join the call in flight
+	time.Sleep(10 * time.Millisecond)
+	if _, err := svc.Get(existing.ID); err != nil {
+		t.Fatalf("expected the lookup to make its own call, got %v", err)
+	}
+
+	if err := <-leaderErr; err == nil {
+		t.Fatal("expected the lookup with the expired context to fail")
+	}
+	if got := listCount(srv); got != 2 {
+		t.Fatalf("expected two list calls, got %d", got)
+	}
+}
+
+func TestListCacheInvalidatedOnMutations(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	existing := srv.AddAccount("existing")
+	svc := newTestService(t, srv)
+
+	if _, err := svc.List(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Get(existing.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := listCount(srv); got != 1 {
+		t.Fatalf("expected the list to be cached, got %d list calls", got)
+	}
+
+	if _, err := svc.Update(existing.ID, "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	a, err := svc.Get(existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "renamed" {
+		t.Fatalf("expected the renamed account, got %q", a.Name)
+	}
+
+	if err := svc.Delete(existing.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Get(existing.ID); !accounts.IsAccountNotFoundErr(err) {
+		t.Fatalf("expected AccountNotFoundError, got %v", err)
+	}
+}
+
+func TestGetFindsAccountsAddedAfterCaching(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	svc := newTestService(t, srv)
+	if _, err := svc.List(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Created outside of the service, so the cache doesn't know about it
+	added := srv.AddAccount("console")
+
+	if _, err := svc.Get(added.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := listCount(srv); got != 2 {
+		t.Fatalf("expected a miss to refresh the list once, got %d list calls", got)
+	}
+}
+
+func TestListReturnsCopy(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	srv.AddAccount("a")
+	svc := newTestService(t, srv)
+
+	l, err := svc.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l[0] = nil
+
+	l, err = svc.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l[0] == nil {
+		t.Fatal("modifying the returned list changed the cache")
+	}
+}
+
+func TestCreateFailsWhenAccountNeverBecomesReady(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	// Hidden from far more list calls than fit into the timeout
+	srv.SetListLag(100)
+
+	svc := newTestService(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	_, err := svc.CreateWithContext(ctx, "never-ready", "arn:aws:iam::123456789012:role/spotinst", "ext-1")
+
+	var notReady *accounts.AccountNotReadyError
+	if !errors.As(err, &notReady) {
+		t.Fatalf("expected AccountNotReadyError, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the error to wrap the deadline, got %v", err)
+	}
+	if got := srv.Accounts(); len(got) != 0 {
+		t.Fatalf("expected the account to be deleted, got %v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	readyPollInitialInterval = 500 * time.Millisecond
+	readyPollMaxInterval     = 5 * time.Second
+)
+
+// AccountNotReadyError is raised when a freshly created account
+// doesn't become visible in the API before the deadline
+type AccountNotReadyError struct {
+	AccountID string
+	Err       error
+}
+
+func (a *AccountNotReadyError) Error() string {
+	return fmt.Sprintf("Account %s was created but never became ready: %v", a.AccountID, a.Err)
+}
+
+func (a *AccountNotReadyError) Unwrap() error {
+	return a.Err
+}
+
+// waitForAccountReady polls the account list until the account with the
+// given id shows up. The API is eventually consistent, so an account
+// returned by create may not be usable for setting up credentials right away.
+// It gives up when ctx is done.
+func (as *Service) waitForAccountReady(ctx context.Context, id string) error {
+	interval := readyPollInitialInterval
+
+	for {
+		_, err := as.GetWithContext(ctx, id)
+		if err == nil {
+			return nil
+		}
+
+		if !IsAccountNotFoundErr(err) {
+			return &AccountNotReadyError{AccountID: id, Err: err}
+		}
+
+		log.Printf("Account %s is not ready yet, checking again in %v\n", id, interval)
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return &AccountNotReadyError{AccountID: id, Err: ctx.Err()}
+		}
+
+		interval *= 2
+		if interval > readyPollMaxInterval {
+			interval = readyPollMaxInterval
+		}
+	}
+}
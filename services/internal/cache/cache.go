@@ -0,0 +1,115 @@
+// Package cache keeps API responses for the lifetime of a service, which
+// is a single Terraform run. It's shared by the services caching lists.
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// FetchFunc loads the value of a key from the API
+type FetchFunc func(ctx context.Context, key string) (interface{}, error)
+
+// Cache keeps one value per key. Concurrent lookups of the same key
+// share one in-flight request.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	value    interface{}
+	valid    bool
+	inflight *call
+
+	// generation is bumped on every invalidation, so a request started
+	// before a mutation can't store a stale value
+	generation uint64
+}
+
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+
+	// abandoned is set when the context of the caller making the request
+	// ended before it finished, the error is of no use to other callers
+	abandoned bool
+}
+
+func (c *Cache) entry(key string) *entry {
+	if c.entries == nil {
+		c.entries = make(map[string]*entry)
+	}
+	e, ok := c.entries[key]
+	if !ok {
+		e = &entry{}
+		c.entries[key] = e
+	}
+	return e
+}
+
+// Get returns the cached value of key or calls fetch, sharing the call with
+// concurrent callers. fromCache reports whether the value was already cached.
+// When the caller making the shared request gives up, the others make their
+// own request instead of failing with its context error.
+func (c *Cache) Get(ctx context.Context, key string, fetch FetchFunc) (value interface{}, fromCache bool, err error) {
+	for {
+		c.mu.Lock()
+		e := c.entry(key)
+		if e.valid {
+			value = e.value
+			c.mu.Unlock()
+			return value, true, nil
+		}
+
+		cl := e.inflight
+		if cl == nil {
+			cl = &call{done: make(chan struct{})}
+			e.inflight = cl
+			generation := e.generation
+			c.mu.Unlock()
+
+			cl.value, cl.err = fetch(ctx, key)
+			cl.abandoned = cl.err != nil && ctx.Err() != nil
+
+			c.mu.Lock()
+			if e.inflight == cl {
+				e.inflight = nil
+			}
+			if cl.err == nil && e.generation == generation {
+				e.value = cl.value
+				e.valid = true
+			}
+			c.mu.Unlock()
+			close(cl.done)
+
+			return cl.value, false, cl.err
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-cl.done:
+			if cl.abandoned && ctx.Err() == nil {
+				continue
+			}
+			return cl.value, false, cl.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// Invalidate drops the cached value of key, the next lookup goes to the API
+func (c *Cache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entry(key).invalidate()
+}
+
+func (e *entry) invalidate() {
+	e.value = nil
+	e.valid = false
+	e.inflight = nil
+	e.generation++
+}
@@ -0,0 +1,31 @@
+package users
+
+import (
+	"context"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/services/internal/cache"
+)
+
+// mappingCache keeps the user mapping of each account for the lifetime of
+// the service, which is a single Terraform run. Concurrent lookups on the
+// same account share one in-flight request.
+type mappingCache struct {
+	cache cache.Cache
+}
+
+// get returns the cached users of an account or calls fetch, sharing the
+// call with concurrent callers. fromCache reports whether the users were
+// already cached.
+func (c *mappingCache) get(ctx context.Context, accountID string, fetch func(context.Context, string) ([]*User, error)) (users []*User, fromCache bool, err error) {
+	v, fromCache, err := c.cache.Get(ctx, accountID, func(ctx context.Context, accountID string) (interface{}, error) {
+		return fetch(ctx, accountID)
+	})
+	users, _ = v.([]*User)
+	return users, fromCache, err
+}
+
+// invalidate drops the cached users of an account, the next lookup
+// goes to the API
+func (c *mappingCache) invalidate(accountID string) {
+	c.cache.Invalidate(accountID)
+}
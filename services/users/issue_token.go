@@ -1,16 +1,16 @@
 package users
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
 )
 
-const usersServiceSignInURL = usersServiceBaseURL + "/auth/signIn"
+const usersServiceSignInPath = "/auth/signIn"
 
 type getConsoleTokenRequest struct {
 	Email    string `json:"email"`
@@ -23,28 +23,33 @@ type getConsoleTokenResponse struct {
 
 // GetConsoleToken issues console token for a given Spotinst user
 func GetConsoleToken(email, password string) (string, error) {
+	return GetConsoleTokenWithContext(context.Background(), email, password)
+}
+
+// GetConsoleTokenWithContext is like GetConsoleToken but the sign in is bound to ctx.
+// The sign in endpoint can be overridden with client.WithBaseURL
+func GetConsoleTokenWithContext(ctx context.Context, email, password string, opts ...client.Option) (string, error) {
 	b := &getConsoleTokenRequest{
 		Email:    email,
 		Password: password,
 	}
 
-	var buf bytes.Buffer
-
-	err := json.NewEncoder(&buf).Encode(b)
-
+	httpClient, err := client.New(usersServiceBaseURL, emptyString, opts...)
 	if err != nil {
 		return emptyString, err
 	}
-	resp, err := http.Post(usersServiceSignInURL, "application/json", &buf)
 
+	req, err := httpClient.NewRequestWithContext(ctx, http.MethodPost, usersServiceSignInPath, b)
 	if err != nil {
-		fmt.Println(err)
 		return emptyString, err
 	}
 
-	var user getConsoleTokenResponse
+	// Signing in twice only issues another token
+	req = client.Idempotent(req)
+
+	var r response
 
-	r, err := readResponseBody(resp.Body)
+	_, err = httpClient.Do(req, &r)
 
 	if err != nil {
 		log.Println(err)
@@ -55,25 +60,9 @@ func GetConsoleToken(email, password string) (string, error) {
 		return emptyString, fmt.Errorf("Cannot get token for %s", email)
 	}
 
+	var user getConsoleTokenResponse
+
 	err = json.Unmarshal(r.Items[0], &user)
 
 	return user.AccessToken, err
 }
-
-func readResponseBody(r io.Reader) (*response, error) {
-	respBytes, err := ioutil.ReadAll(r)
-
-	if err != nil {
-		return nil, err
-	}
-
-	var resp response
-
-	err = json.Unmarshal(respBytes, &resp)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &resp, nil
-}
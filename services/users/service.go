@@ -1,6 +1,7 @@
 package users
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,16 +18,48 @@ const (
 	emptyString         = ``
 )
 
+// Permission strategies of programmatic users
+const (
+	PermissionStrategyRoleBased   = "ROLE_BASED"
+	PermissionStrategyPolicyBased = "POLICY_BASED"
+)
+
+// Account roles of role based programmatic users
+const (
+	AccountRoleViewer = 1
+	AccountRoleEditor = 2
+	AccountRoleAdmin  = 3
+)
+
+// Permissions define what a programmatic user may do in its account.
+// Role based users get AccountRole, policy based users get PolicyIDs.
+type Permissions struct {
+	Strategy    string
+	AccountRole int
+	PolicyIDs   []int
+}
+
+// DefaultPermissions make an editor of the account
+var DefaultPermissions = Permissions{
+	Strategy:    PermissionStrategyRoleBased,
+	AccountRole: AccountRoleEditor,
+}
+
 // Service ...
 type Service struct {
 	httpClient *client.Client
+	cache      mappingCache
 }
 
 // New ..
-func New(token string) *Service {
-	return &Service{
-		httpClient: client.New(usersServiceBaseURL, token),
+func New(token string, opts ...client.Option) (*Service, error) {
+	httpClient, err := client.New(usersServiceBaseURL, token, opts...)
+	if err != nil {
+		return nil, err
 	}
+	return &Service{
+		httpClient: httpClient,
+	}, nil
 }
 
 // User ...
@@ -59,7 +92,34 @@ type User struct {
 		FirstName string `json:"firstName"`
 		Type      string
 	} `json:"coreUser"`
-	AccountID string `json:"accountId"`
+	AccountID          string `json:"accountId"`
+	PermissionStrategy string `json:"permissionStrategy"`
+}
+
+// UserNotFoundError is raised when looking up a user fails
+// because the account has no such user
+type UserNotFoundError struct {
+	AccountID string
+	UserID    int
+	UserName  string
+}
+
+func (u *UserNotFoundError) Error() string {
+	if u.UserName != "" {
+		return fmt.Sprintf("User %s not found in account %s", u.UserName, u.AccountID)
+	}
+	return fmt.Sprintf("User %d not found in account %s", u.UserID, u.AccountID)
+}
+
+// IsUserNotFoundErr checks whether errors is of type UserNotFoundError
+func IsUserNotFoundErr(err error) bool {
+	var found bool
+	switch err.(type) {
+	case *UserNotFoundError:
+		found = true
+	default:
+	}
+	return found
 }
 
 type createProgrammaticUserRequest struct {
@@ -79,17 +139,45 @@ type createProgrammaticUserResponse struct {
 
 // Create ..
 func (us *Service) Create(username, description, accountID string) (*User, error) {
+	return us.CreateWithContext(context.Background(), username, description, accountID)
+}
+
+// CreateWithContext is like Create but the requests are bound to ctx
+func (us *Service) CreateWithContext(ctx context.Context, username, description, accountID string) (*User, error) {
+	return us.CreateWithPermissionsContext(ctx, username, description, accountID, DefaultPermissions)
+}
+
+// CreateWithPermissionsContext creates a programmatic user with the given
+// permissions in the account
+func (us *Service) CreateWithPermissionsContext(ctx context.Context, username, description, accountID string, perms Permissions) (*User, error) {
+
+	// The API doesn't return the ID of the new user, it's the one
+	// carrying the name that wasn't there before. The cached mapping
+	// may be stale, so the known users come from the API.
+	existing, err := us.fetchUsers(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int]bool, len(existing))
+	for _, u := range existing {
+		known[u.CoreUser.ID] = true
+	}
+
+	policyIDs := perms.PolicyIDs
+	if policyIDs == nil {
+		policyIDs = []int{}
+	}
 
 	b := &createProgrammaticUserRequest{
-		AccountRole:        2,
+		AccountRole:        perms.AccountRole,
 		Accounts:           []string{accountID},
 		Description:        description,
 		Name:               username,
-		PermissionStrategy: "ROLE_BASED",
-		PolicyIds:          []int{},
+		PermissionStrategy: perms.Strategy,
+		PolicyIds:          policyIDs,
 	}
 
-	req, err := us.httpClient.NewRequest(http.MethodPost, "/setup/shared/ums/programmaticUser", b)
+	req, err := us.httpClient.NewRequestWithContext(ctx, http.MethodPost, "/setup/shared/ums/programmaticUser", b)
 	if err != nil {
 		return nil, err
 	}
@@ -97,6 +185,7 @@ func (us *Service) Create(username, description, accountID string) (*User, error
 	var responseBody response
 
 	_, err = us.httpClient.Do(req, &responseBody)
+	us.cache.invalidate(accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -112,8 +201,7 @@ func (us *Service) Create(username, description, accountID string) (*User, error
 		return nil, err
 	}
 
-	log.Printf("IN CREATE: %v\n", accountID)
-	user, err := us.Get(username, accountID)
+	user, err := us.waitForNewUser(ctx, accountID, username, known)
 	if err != nil {
 		return nil, err
 	}
@@ -124,13 +212,49 @@ func (us *Service) Create(username, description, accountID string) (*User, error
 
 // Get ...
 func (us *Service) Get(username, accountID string) (*User, error) {
+	return us.GetWithContext(context.Background(), username, accountID)
+}
 
-	req, err := us.httpClient.NewRequest(http.MethodGet, "/setup/shared/accountUserMapping", nil)
+// GetWithContext is like Get but the request is bound to ctx
+func (us *Service) GetWithContext(ctx context.Context, username, accountID string) (*User, error) {
+	userList, fromCache, err := us.cache.get(ctx, accountID, us.fetchUsers)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("IN GET: %v\n", accountID)
+	// The user may have been created after the mapping was cached,
+	// so a miss is only trusted when the mapping is fresh
+	if fromCache {
+		if user, err := filterUserByName(accountID, username, userList); err == nil {
+			return user, nil
+		}
+		us.cache.invalidate(accountID)
+		if userList, _, err = us.cache.get(ctx, accountID, us.fetchUsers); err != nil {
+			return nil, err
+		}
+	}
+
+	return filterUserByName(accountID, username, userList)
+}
+
+// ListWithContext returns all users mapped to an account. The users are
+// cached until a user of the account is created or deleted through the service.
+func (us *Service) ListWithContext(ctx context.Context, accountID string) ([]*User, error) {
+	userList, _, err := us.cache.get(ctx, accountID, us.fetchUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers may reorder the list, the cached one must stay intact
+	return append([]*User(nil), userList...), nil
+}
+
+func (us *Service) fetchUsers(ctx context.Context, accountID string) ([]*User, error) {
+
+	req, err := us.httpClient.NewRequestWithContext(ctx, http.MethodGet, "/setup/shared/accountUserMapping", nil)
+	if err != nil {
+		return nil, err
+	}
 
 	u, _ := url.ParseQuery(req.URL.RawQuery)
 	u.Add("spotinstAccountId", accountID)
@@ -145,17 +269,34 @@ func (us *Service) Get(username, accountID string) (*User, error) {
 		return nil, err
 	}
 
-	userList, err := usersFromJSON(r)
+	return usersFromJSON(r)
+}
 
+// GetByID returns the user with the given core user ID from an account
+func (us *Service) GetByID(accountID string, userID int) (*User, error) {
+	return us.GetByIDWithContext(context.Background(), accountID, userID)
+}
+
+// GetByIDWithContext is like GetByID but the request is bound to ctx
+func (us *Service) GetByIDWithContext(ctx context.Context, accountID string, userID int) (*User, error) {
+	userList, fromCache, err := us.cache.get(ctx, accountID, us.fetchUsers)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(userList) == 0 {
-		return nil, errors.New("Cannot get users")
+	user, err := filterUserByID(accountID, userID, userList)
+
+	// The user may have been created after the mapping was cached,
+	// so a miss is only trusted when the mapping is fresh
+	if IsUserNotFoundErr(err) && fromCache {
+		us.cache.invalidate(accountID)
+		if userList, _, err = us.cache.get(ctx, accountID, us.fetchUsers); err != nil {
+			return nil, err
+		}
+		user, err = filterUserByID(accountID, userID, userList)
 	}
 
-	return filterUserByName(username, userList)
+	return user, err
 }
 
 func usersFromJSON(r response) ([]*User, error) {
@@ -174,16 +315,50 @@ func usersFromJSON(r response) ([]*User, error) {
 
 }
 
-func filterUserByName(username string, ul []*User) (*User, error) {
+func filterUserByName(accountID, username string, ul []*User) (*User, error) {
 	for _, u := range ul {
 
 		log.Printf("%v\n", u)
 		log.Printf("Checking %v with %v\n", u.CoreUser.FirstName, username)
-		if strings.ToLower(u.CoreUser.FirstName) == username {
+		if strings.EqualFold(u.CoreUser.FirstName, username) {
 			return u, nil
 		}
 	}
-	return nil, fmt.Errorf("User %s not found", username)
+	return nil, &UserNotFoundError{AccountID: accountID, UserName: username}
+}
+
+// filterNewUser returns the user named username that isn't one of known.
+// Several of them mean another user of that name was created concurrently,
+// and there's no telling which one is ours.
+func filterNewUser(accountID, username string, known map[int]bool, ul []*User) (*User, error) {
+	var found []*User
+	for _, u := range ul {
+		if !known[u.CoreUser.ID] && strings.EqualFold(u.CoreUser.FirstName, username) {
+			found = append(found, u)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, &UserNotFoundError{AccountID: accountID, UserName: username}
+	case 1:
+		return found[0], nil
+	default:
+		ids := make([]int, len(found))
+		for i, u := range found {
+			ids[i] = u.CoreUser.ID
+		}
+		return nil, fmt.Errorf("Cannot tell which user was created, users %v named %s were added to account %s at the same time", ids, username, accountID)
+	}
+}
+
+func filterUserByID(accountID string, userID int, ul []*User) (*User, error) {
+	for _, u := range ul {
+		if u.CoreUser.ID == userID {
+			return u, nil
+		}
+	}
+	return nil, &UserNotFoundError{AccountID: accountID, UserID: userID}
 }
 
 // Update ...
@@ -193,13 +368,30 @@ func (us *Service) Update(u *User) (*User, error) {
 
 // Delete ...
 func (us *Service) Delete(username, accountID string) error {
-	user, err := us.Get(username, accountID)
+	return us.DeleteWithContext(context.Background(), username, accountID)
+}
+
+// DeleteWithContext is like Delete but the requests are bound to ctx
+func (us *Service) DeleteWithContext(ctx context.Context, username, accountID string) error {
+	user, err := us.GetWithContext(ctx, username, accountID)
 	if err != nil {
 		return err
 	}
 
-	log.Println(user.CoreUser.ID)
-	req, err := us.httpClient.NewRequest(http.MethodDelete, fmt.Sprintf("/setup/shared/ums/user/%v", user.CoreUser.ID), nil)
+	return us.DeleteByIDWithContext(ctx, accountID, user.CoreUser.ID)
+}
+
+// DeleteByID deletes the user with the given core user ID from an account
+func (us *Service) DeleteByID(accountID string, userID int) error {
+	return us.DeleteByIDWithContext(context.Background(), accountID, userID)
+}
+
+// DeleteByIDWithContext is like DeleteByID but the request is bound to ctx
+func (us *Service) DeleteByIDWithContext(ctx context.Context, accountID string, userID int) error {
+	req, err := us.httpClient.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf("/setup/shared/ums/user/%d", userID), nil)
+	if err != nil {
+		return err
+	}
 
 	u, _ := url.ParseQuery(req.URL.RawQuery)
 
@@ -207,17 +399,13 @@ func (us *Service) Delete(username, accountID string) error {
 
 	req.URL.RawQuery = u.Encode()
 
-	resp, err := us.httpClient.Do(req, nil)
+	_, err = us.httpClient.Do(req, nil)
+	us.cache.invalidate(accountID)
 
 	if err != nil {
 		log.Println(err)
-		return err
+		return fmt.Errorf("Cannot delete user %d: %w", userID, err)
 	}
 
-	log.Printf("RESPONSE !!!!!!!!!!!! %#v", resp)
-
-	if resp.StatusCode > 399 {
-		return errors.New("Cannot delete user: " + user.CoreUser.FirstName)
-	}
 	return nil
 }
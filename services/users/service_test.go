@@ -0,0 +1,239 @@
+package users_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/services/users"
+	"github.com/cnicolov/terraform-provider-spotinstadmin/testing/fakespotinst"
+)
+
+func newTestService(t *testing.T, srv *fakespotinst.Server) *users.Service {
+	svc, err := users.New(srv.ConsoleToken, client.WithBaseURL(srv.URL), client.WithRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func mappingCount(srv *fakespotinst.Server) int {
+	return srv.RequestCount(http.MethodGet, "/setup/shared/accountUserMapping")
+}
+
+func TestGetSharesConcurrentMappingCalls(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	account := srv.AddAccount("a")
+	names := []string{"one", "two", "three", "four"}
+
+	seed := newTestService(t, srv)
+	for _, name := range names {
+		if _, err := seed.Create(name, "", account.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Keep the first mapping call in flight long enough for all lookups to join it
+	srv.InjectFault(fakespotinst.Fault{Method: http.MethodGet, Path: "/setup/shared/accountUserMapping", Latency: 100 * time.Millisecond, Times: 1})
+
+	before := mappingCount(srv)
+	svc := newTestService(t, srv)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(names)*2)
+	for i := 0; i < 2; i++ {
+		for _, name := range names {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				_, err := svc.Get(name, account.ID)
+				errs <- err
+			}(name)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := mappingCount(srv) - before; got != 1 {
+		t.Fatalf("expected one mapping call, got %d", got)
+	}
+}
+
+func TestMappingCachedPerAccount(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	first := srv.AddAccount("first")
+	second := srv.AddAccount("second")
+	svc := newTestService(t, srv)
+
+	for i := 0; i < 3; i++ {
+		for _, id := range []string{first.ID, second.ID} {
+			if _, err := svc.ListWithContext(context.Background(), id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if got := mappingCount(srv); got != 2 {
+		t.Fatalf("expected one mapping call per account, got %d", got)
+	}
+}
+
+func TestMappingCacheInvalidatedOnMutations(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	account := srv.AddAccount("a")
+	svc := newTestService(t, srv)
+
+	if _, err := svc.Create("first", "", account.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Create("second", "", account.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Create asks the API directly, the first lookup fills the cache
+	if _, err := svc.Get("first", account.ID); err != nil {
+		t.Fatal(err)
+	}
+	before := mappingCount(srv)
+
+	for _, name := range []string{"first", "second"} {
+		if _, err := svc.Get(name, account.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := mappingCount(srv) - before; got != 0 {
+		t.Fatalf("expected lookups to use the cached mapping, got %d mapping calls", got)
+	}
+
+	if err := svc.Delete("first", account.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Get("first", account.ID); !users.IsUserNotFoundErr(err) {
+		t.Fatalf("expected UserNotFoundError, got %v", err)
+	}
+	if _, err := svc.Get("second", account.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetFindsUsersAddedAfterCaching(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	account := srv.AddAccount("a")
+	svc := newTestService(t, srv)
+	if _, err := svc.ListWithContext(context.Background(), account.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Created through another service, so the cache doesn't know about it
+	if _, err := newTestService(t, srv).Create("other", "", account.ID); err != nil {
+		t.Fatal(err)
+	}
+	before := mappingCount(srv)
+
+	if _, err := svc.Get("other", account.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := mappingCount(srv) - before; got != 1 {
+		t.Fatalf("expected a miss to refresh the mapping once, got %d mapping calls", got)
+	}
+}
+
+func TestCreateWaitsForNewUser(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	account := srv.AddAccount("a")
+	svc := newTestService(t, srv)
+
+	// A user of the same name added outside of the service after
+	// the mapping was cached
+	if _, err := svc.ListWithContext(context.Background(), account.ID); err != nil {
+		t.Fatal(err)
+	}
+	existing := srv.AddUser(account.ID, "ci")
+
+	// The new user only shows up in the second mapping call after create
+	srv.SetListLag(1)
+
+	u, err := svc.Create("ci", "", account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.CoreUser.ID == existing.ID {
+		t.Fatalf("expected the created user, got the existing user %d", existing.ID)
+	}
+	if _, ok := srv.User(u.CoreUser.ID); !ok {
+		t.Fatalf("user %d doesn't exist", u.CoreUser.ID)
+	}
+}
+
+func TestCreateFailsOnConcurrentUsersOfTheSameName(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	account := srv.AddAccount("a")
+	svc := newTestService(t, srv)
+
+	// Added concurrently, it's missing from the mapping Create starts
+	// with and shows up along with the created user
+	srv.SetListLag(1)
+	srv.AddUser(account.ID, "ci")
+	srv.SetListLag(0)
+
+	if _, err := svc.Create("ci", "", account.ID); err == nil || !strings.Contains(err.Error(), "Cannot tell which user was created") {
+		t.Fatalf("expected an error about several new users, got %v", err)
+	}
+}
+
+func TestGetByIDAndDeleteByID(t *testing.T) {
+	srv := fakespotinst.New()
+	defer srv.Close()
+
+	account := srv.AddAccount("a")
+	other := srv.AddUser(account.ID, "CI-Robot")
+	svc := newTestService(t, srv)
+
+	created, err := svc.Create("CI-Robot", "", account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.CoreUser.ID == other.ID {
+		t.Fatalf("Create picked up the existing user %d", other.ID)
+	}
+
+	u, err := svc.GetByID(account.ID, created.CoreUser.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.CoreUser.FirstName != "CI-Robot" {
+		t.Fatalf("expected user CI-Robot, got %q", u.CoreUser.FirstName)
+	}
+
+	if err := svc.DeleteByID(account.ID, created.CoreUser.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetByID(account.ID, created.CoreUser.ID); !users.IsUserNotFoundErr(err) {
+		t.Fatalf("expected UserNotFoundError, got %v", err)
+	}
+	if _, err := svc.GetByID(account.ID, other.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteByID(account.ID, created.CoreUser.ID); !client.IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	readyPollInitialInterval = 500 * time.Millisecond
+	readyPollMaxInterval     = 5 * time.Second
+)
+
+// UserNotReadyError is raised when a freshly created user doesn't
+// show up in the account user mapping before the deadline
+type UserNotReadyError struct {
+	AccountID string
+	UserName  string
+	Err       error
+}
+
+func (u *UserNotReadyError) Error() string {
+	return fmt.Sprintf("User %s was created in account %s but never showed up: %v", u.UserName, u.AccountID, u.Err)
+}
+
+func (u *UserNotReadyError) Unwrap() error {
+	return u.Err
+}
+
+// waitForNewUser polls the user mapping of the account until a user with
+// the given name shows up that isn't one of known. The API is eventually
+// consistent and doesn't return the ID of a created user, so this is the
+// only way to tell which user was created. It gives up when ctx is done.
+func (us *Service) waitForNewUser(ctx context.Context, accountID, username string, known map[int]bool) (*User, error) {
+	interval := readyPollInitialInterval
+
+	for {
+		userList, err := us.fetchUsers(ctx, accountID)
+		if err != nil {
+			return nil, &UserNotReadyError{AccountID: accountID, UserName: username, Err: err}
+		}
+
+		user, err := filterNewUser(accountID, username, known, userList)
+		if !IsUserNotFoundErr(err) {
+			return user, err
+		}
+
+		log.Printf("User %s is not mapped to account %s yet, checking again in %v\n", username, accountID, interval)
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, &UserNotReadyError{AccountID: accountID, UserName: username, Err: ctx.Err()}
+		}
+
+		interval *= 2
+		if interval > readyPollMaxInterval {
+			interval = readyPollMaxInterval
+		}
+	}
+}
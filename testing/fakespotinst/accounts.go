@@ -0,0 +1,329 @@
+package fakespotinst
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const accountPath = "/setup/account"
+
+func (s *Server) serveAccounts(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == accountPath && r.Method == http.MethodGet:
+		s.listAccounts(w, r)
+	case r.URL.Path == accountPath && r.Method == http.MethodPost:
+		s.createAccount(w, r)
+	case strings.HasPrefix(r.URL.Path, accountPath+"/") && r.Method == http.MethodPut:
+		s.updateAccount(w, r, strings.TrimPrefix(r.URL.Path, accountPath+"/"))
+	case strings.HasPrefix(r.URL.Path, accountPath+"/") && r.Method == http.MethodDelete:
+		s.deleteAccount(w, r, strings.TrimPrefix(r.URL.Path, accountPath+"/"))
+	case r.URL.Path == "/setup/credentials/aws" && r.Method == http.MethodPost:
+		s.setAWSCredentials(w, r)
+	case r.URL.Path == "/setup/credentials/aws" && r.Method == http.MethodGet:
+		s.getAWSCredentials(w, r)
+	case r.URL.Path == "/setup/credentials/aws/externalId" && r.Method == http.MethodPost:
+		s.generateAWSExternalID(w, r)
+	case r.URL.Path == "/azure/setup/credentials" && r.Method == http.MethodPost:
+		s.setAzureCredentials(w, r)
+	case r.URL.Path == "/gcp/setup/credentials" && r.Method == http.MethodPost:
+		s.setGCPCredentials(w, r)
+	default:
+		writeError(w, r, http.StatusNotFound, "NOT_FOUND", "no such endpoint", "")
+	}
+}
+
+func (s *Server) listAccounts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.accounts))
+	for id := range s.accounts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	items := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		a := s.accounts[id]
+		if a.visibleAfter > 0 {
+			a.visibleAfter--
+			continue
+		}
+		items = append(items, accountJSON(a, "accountId"))
+	}
+	s.mu.Unlock()
+
+	writeItems(w, r, "spotinst:setup:account", items...)
+}
+
+func (s *Server) createAccount(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Account struct {
+			Name string `json:"name"`
+		} `json:"account"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, r, http.StatusBadRequest, "INVALID_JSON", err.Error(), "")
+		return
+	}
+	if body.Account.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "name is required", "account.name")
+		return
+	}
+
+	s.mu.Lock()
+	a := s.addAccount(body.Account.Name)
+	s.mu.Unlock()
+
+	writeItems(w, r, "spotinst:setup:account", accountJSON(a, "id"))
+}
+
+func (s *Server) updateAccount(w http.ResponseWriter, r *http.Request, id string) {
+	var body struct {
+		Account struct {
+			Name string `json:"name"`
+		} `json:"account"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, r, http.StatusBadRequest, "INVALID_JSON", err.Error(), "")
+		return
+	}
+	if body.Account.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "name is required", "account.name")
+		return
+	}
+
+	s.mu.Lock()
+	a, ok := s.accounts[id]
+	var out map[string]interface{}
+	if ok {
+		a.Name = body.Account.Name
+		out = accountJSON(a, "id")
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "ACCOUNT_NOT_FOUND", "account "+id+" not found", "")
+		return
+	}
+	writeItems(w, r, "spotinst:setup:account", out)
+}
+
+func (s *Server) deleteAccount(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	_, ok := s.accounts[id]
+	if ok {
+		delete(s.accounts, id)
+		// Users mapped to no other account go away with it
+		for userID, u := range s.users {
+			delete(u.Accounts, id)
+			if len(u.Accounts) == 0 {
+				delete(s.users, userID)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "ACCOUNT_NOT_FOUND", "account "+id+" not found", "")
+		return
+	}
+	writeItems(w, r, "")
+}
+
+func (s *Server) setAWSCredentials(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Credentials struct {
+			IAMRole    string `json:"iamRole"`
+			ExternalID string `json:"externalId"`
+		} `json:"credentials"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, r, http.StatusBadRequest, "INVALID_JSON", err.Error(), "")
+		return
+	}
+
+	id := r.URL.Query().Get("accountId")
+
+	s.mu.Lock()
+	a, ok := s.accounts[id]
+	if ok {
+		a.Credentials = &AWSCredentials{
+			IAMRole:    body.Credentials.IAMRole,
+			ExternalID: body.Credentials.ExternalID,
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "ACCOUNT_NOT_FOUND", "account "+id+" not found", "accountId")
+		return
+	}
+	writeItems(w, r, "")
+}
+
+func (s *Server) getAWSCredentials(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("accountId")
+
+	s.mu.Lock()
+	a, ok := s.accounts[id]
+	var creds *AWSCredentials
+	if ok {
+		creds = a.Credentials
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "ACCOUNT_NOT_FOUND", "account "+id+" not found", "accountId")
+		return
+	}
+	if creds == nil {
+		writeError(w, r, http.StatusNotFound, "CREDENTIALS_NOT_FOUND", "account "+id+" has no AWS credentials", "")
+		return
+	}
+
+	// The external ID is write-only, like in the real API
+	writeItems(w, r, "spotinst:setup:credentials:aws", map[string]interface{}{
+		"accountId": id,
+		"iamRole":   creds.IAMRole,
+	})
+}
+
+func (s *Server) generateAWSExternalID(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("accountId")
+
+	s.mu.Lock()
+	_, ok := s.accounts[id]
+	s.nextID++
+	externalID := fmt.Sprintf("spotinst:aws:extid:%08x", s.nextID)
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "ACCOUNT_NOT_FOUND", "account "+id+" not found", "accountId")
+		return
+	}
+	writeItems(w, r, "spotinst:setup:credentials:aws:externalId", map[string]interface{}{
+		"externalId": externalID,
+	})
+}
+
+func (s *Server) setAzureCredentials(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		TenantID       string `json:"tenantId"`
+		ClientID       string `json:"clientId"`
+		ClientSecret   string `json:"clientSecret"`
+		SubscriptionID string `json:"subscriptionId"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, r, http.StatusBadRequest, "INVALID_JSON", err.Error(), "")
+		return
+	}
+	for field, value := range map[string]string{
+		"tenantId":       body.TenantID,
+		"clientId":       body.ClientID,
+		"clientSecret":   body.ClientSecret,
+		"subscriptionId": body.SubscriptionID,
+	} {
+		if value == "" {
+			writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", field+" is required", field)
+			return
+		}
+	}
+
+	id := r.URL.Query().Get("accountId")
+
+	s.mu.Lock()
+	a, ok := s.accounts[id]
+	if ok {
+		a.Azure = &AzureCredentials{
+			TenantID:       body.TenantID,
+			ClientID:       body.ClientID,
+			ClientSecret:   body.ClientSecret,
+			SubscriptionID: body.SubscriptionID,
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "ACCOUNT_NOT_FOUND", "account "+id+" not found", "accountId")
+		return
+	}
+	writeItems(w, r, "")
+}
+
+func (s *Server) setGCPCredentials(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ServiceAccount json.RawMessage `json:"serviceAccount"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, r, http.StatusBadRequest, "INVALID_JSON", err.Error(), "")
+		return
+	}
+
+	var key struct {
+		Type        string `json:"type"`
+		ProjectID   string `json:"project_id"`
+		PrivateKey  string `json:"private_key"`
+		ClientEmail string `json:"client_email"`
+	}
+	if err := json.Unmarshal(body.ServiceAccount, &key); err != nil || key.Type != "service_account" || key.PrivateKey == "" {
+		writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "invalid service account key", "serviceAccount")
+		return
+	}
+
+	id := r.URL.Query().Get("accountId")
+
+	s.mu.Lock()
+	a, ok := s.accounts[id]
+	if ok {
+		a.GCP = &GCPCredentials{
+			ProjectID:      key.ProjectID,
+			ClientEmail:    key.ClientEmail,
+			ServiceAccount: string(body.ServiceAccount),
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "ACCOUNT_NOT_FOUND", "account "+id+" not found", "accountId")
+		return
+	}
+	writeItems(w, r, "")
+}
+
+// accountJSON renders an account the way the API does. Create returns the
+// ID as "id" while the list uses "accountId".
+func accountJSON(a *Account, idKey string) map[string]interface{} {
+	out := map[string]interface{}{
+		idKey:            a.ID,
+		"name":           a.Name,
+		"organizationId": a.OrganizationID,
+		"createdAt":      a.CreatedAt.Format(time.RFC3339),
+	}
+
+	switch {
+	case a.Credentials != nil:
+		out["cloudProvider"] = "AWS"
+		out["providerExternalId"] = awsAccountID(a.Credentials.IAMRole)
+	case a.Azure != nil:
+		out["cloudProvider"] = "AZURE"
+		out["providerExternalId"] = a.Azure.SubscriptionID
+	case a.GCP != nil:
+		out["cloudProvider"] = "GCP"
+		out["providerExternalId"] = a.GCP.ProjectID
+	}
+
+	return out
+}
+
+// awsAccountID extracts the AWS account ID from a role ARN
+// like arn:aws:iam::123456789012:role/name
+func awsAccountID(roleArn string) string {
+	parts := strings.Split(roleArn, ":")
+	if len(parts) < 5 {
+		return ""
+	}
+	return parts[4]
+}
@@ -0,0 +1,88 @@
+package fakespotinst
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/cnicolov/terraform-provider-spotinstadmin/client/common"
+)
+
+var requestSeq uint64
+
+type requestInfo struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	Method    string `json:"method"`
+	Timestamp string `json:"timestamp"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiEnvelope is the envelope of api.spotinst.io
+type apiEnvelope struct {
+	Request  requestInfo `json:"request"`
+	Response struct {
+		Status status                 `json:"status"`
+		Kind   string                 `json:"kind,omitempty"`
+		Items  []interface{}          `json:"items"`
+		Count  int                    `json:"count"`
+		Errors []common.ResponseError `json:"errors,omitempty"`
+	} `json:"response"`
+}
+
+// consoleEnvelope is the envelope of console.spotinst.com
+type consoleEnvelope struct {
+	Kind  string        `json:"kind"`
+	Items []interface{} `json:"items"`
+}
+
+func newRequestInfo(r *http.Request) requestInfo {
+	return requestInfo{
+		ID:        fmt.Sprintf("fake-%d", atomic.AddUint64(&requestSeq, 1)),
+		URL:       r.URL.String(),
+		Method:    r.Method,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+func writeItems(w http.ResponseWriter, r *http.Request, kind string, items ...interface{}) {
+	var env apiEnvelope
+	env.Request = newRequestInfo(r)
+	env.Response.Status = status{Code: http.StatusOK, Message: "OK"}
+	env.Response.Kind = kind
+	env.Response.Items = append([]interface{}{}, items...)
+	env.Response.Count = len(items)
+	writeJSON(w, http.StatusOK, env)
+}
+
+func writeConsoleItems(w http.ResponseWriter, kind string, items ...interface{}) {
+	writeJSON(w, http.StatusOK, consoleEnvelope{
+		Kind:  kind,
+		Items: append([]interface{}{}, items...),
+	})
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, statusCode int, code, message, field string) {
+	var env apiEnvelope
+	env.Request = newRequestInfo(r)
+	env.Response.Status = status{Code: statusCode, Message: http.StatusText(statusCode)}
+	env.Response.Items = []interface{}{}
+	env.Response.Errors = []common.ResponseError{{Code: code, Message: message, Field: field}}
+	writeJSON(w, statusCode, env)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	return json.NewDecoder(r.Body).Decode(v)
+}
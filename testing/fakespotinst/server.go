@@ -0,0 +1,331 @@
+// Package fakespotinst implements an in-memory stand-in for the parts of
+// the Spotinst API used by the provider, so tests can run without network
+package fakespotinst
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default credentials accepted by a new Server
+const (
+	DefaultAPIToken       = "fake-api-token"
+	DefaultConsoleToken   = "fake-console-token"
+	DefaultEmail          = "admin@example.com"
+	DefaultPassword       = "secret"
+	DefaultOrganizationID = "606079874257"
+)
+
+// Account is an account as stored by the fake API
+type Account struct {
+	ID             string
+	Name           string
+	OrganizationID string
+	CreatedAt      time.Time
+	Credentials    *AWSCredentials
+	Azure          *AzureCredentials
+	GCP            *GCPCredentials
+
+	// visibleAfter is the number of list requests the account
+	// is still hidden from, see SetListLag
+	visibleAfter int
+}
+
+// AWSCredentials are the AWS credentials set up for an account
+type AWSCredentials struct {
+	IAMRole    string
+	ExternalID string
+}
+
+// AzureCredentials are the Azure credentials set up for an account
+type AzureCredentials struct {
+	TenantID       string
+	ClientID       string
+	ClientSecret   string
+	SubscriptionID string
+}
+
+// GCPCredentials are the GCP credentials set up for an account
+type GCPCredentials struct {
+	ProjectID      string
+	ClientEmail    string
+	ServiceAccount string
+}
+
+// User is a programmatic user mapped to one or more accounts
+type User struct {
+	ID                 int
+	Name               string
+	Description        string
+	Token              string
+	PermissionStrategy string
+
+	// Accounts holds the permissions of the user in each
+	// account it is mapped to
+	Accounts map[string]AccountMapping
+
+	visibleAfter int
+}
+
+// AccountMapping holds the permissions of a user in one account
+type AccountMapping struct {
+	AccountRole int
+	PolicyIDs   []int
+}
+
+func (u *User) copy() User {
+	c := *u
+	c.Accounts = make(map[string]AccountMapping, len(u.Accounts))
+	for id, m := range u.Accounts {
+		c.Accounts[id] = m
+	}
+	return c
+}
+
+// Fault makes matching requests fail or slow down. Method and Path are
+// matched exactly unless empty, Path is compared without the query string.
+type Fault struct {
+	Method     string
+	Path       string
+	StatusCode int
+	RetryAfter string
+	Latency    time.Duration
+
+	// Times limits how many requests are affected, 0 means all of them
+	Times int
+}
+
+// Server is a fake Spotinst API on top of httptest.Server. Both the
+// accounts and the users API are served from the same URL.
+type Server struct {
+	*httptest.Server
+
+	APIToken     string
+	ConsoleToken string
+	Email        string
+	Password     string
+
+	mu       sync.Mutex
+	accounts map[string]*Account
+	users    map[int]*User
+	faults   []*Fault
+	requests map[string]int
+	listLag  int
+	nextID   int
+}
+
+// New starts a fake Spotinst API, callers must Close it
+func New() *Server {
+	s := &Server{
+		APIToken:     DefaultAPIToken,
+		ConsoleToken: DefaultConsoleToken,
+		Email:        DefaultEmail,
+		Password:     DefaultPassword,
+		accounts:     make(map[string]*Account),
+		users:        make(map[int]*User),
+		requests:     make(map[string]int),
+		nextID:       1000,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// InjectFault registers f for the following requests. Faults are
+// evaluated in the order they were injected.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fault := f
+	s.faults = append(s.faults, &fault)
+}
+
+// ClearFaults removes every injected fault
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// SetListLag simulates eventual consistency: accounts and users created
+// afterwards stay hidden from the next n list requests
+func (s *Server) SetListLag(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listLag = n
+}
+
+// RequestCount returns how many requests were received for method and
+// path, path is compared without the query string
+func (s *Server) RequestCount(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[method+" "+path]
+}
+
+// AddAccount stores an account directly, as if it was created in the console
+func (s *Server) AddAccount(name string) Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.addAccount(name)
+}
+
+// SetAWSCredentials replaces the credentials of an account, as if they
+// were changed in the console
+func (s *Server) SetAWSCredentials(accountID string, creds AWSCredentials) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.accounts[accountID]
+	if ok {
+		a.Credentials = &creds
+	}
+	return ok
+}
+
+// Account returns a copy of the stored account
+func (s *Server) Account(id string) (Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.accounts[id]
+	if !ok {
+		return Account{}, false
+	}
+	return *a, true
+}
+
+// Accounts returns copies of all stored accounts
+func (s *Server) Accounts() []Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		out = append(out, *a)
+	}
+	return out
+}
+
+// AddUser stores a programmatic user mapped to accountID directly, as if
+// it was created in the console
+func (s *Server) AddUser(accountID, name string) User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	u := &User{
+		ID:                 s.nextID,
+		Name:               name,
+		Token:              fmt.Sprintf("fake-user-token-%d", s.nextID),
+		PermissionStrategy: "ROLE_BASED",
+		Accounts: map[string]AccountMapping{
+			accountID: {AccountRole: 2},
+		},
+		visibleAfter: s.listLag,
+	}
+	s.users[u.ID] = u
+	return u.copy()
+}
+
+// User returns a copy of the stored user
+func (s *Server) User(id int) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return User{}, false
+	}
+	return u.copy(), true
+}
+
+// Users returns copies of all users mapped to accountID
+func (s *Server) Users(accountID string) []User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []User
+	for _, u := range s.users {
+		if _, ok := u.Accounts[accountID]; ok {
+			out = append(out, u.copy())
+		}
+	}
+	return out
+}
+
+func (s *Server) addAccount(name string) *Account {
+	s.nextID++
+	a := &Account{
+		ID:             fmt.Sprintf("act-%08x", s.nextID),
+		Name:           name,
+		OrganizationID: DefaultOrganizationID,
+		CreatedAt:      time.Now().UTC(),
+		visibleAfter:   s.listLag,
+	}
+	s.accounts[a.ID] = a
+	return a
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.Method+" "+r.URL.Path]++
+	fault := s.matchFault(r)
+	s.mu.Unlock()
+
+	if fault != nil {
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.StatusCode != 0 {
+			if fault.RetryAfter != "" {
+				w.Header().Set("Retry-After", fault.RetryAfter)
+			}
+			writeError(w, r, fault.StatusCode, "FAULT_INJECTED", "fault injected by fakespotinst", "")
+			return
+		}
+	}
+
+	switch {
+	case r.URL.Path == "/auth/signIn":
+		s.signIn(w, r)
+		return
+	case strings.HasPrefix(r.URL.Path, "/setup/shared/"):
+		if !s.authorized(r, s.ConsoleToken) {
+			writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "invalid console token", "")
+			return
+		}
+		s.serveUsers(w, r)
+		return
+	}
+
+	if !s.authorized(r, s.APIToken) {
+		writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "invalid API token", "")
+		return
+	}
+	s.serveAccounts(w, r)
+}
+
+func (s *Server) matchFault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if f.Method != "" && f.Method != r.Method {
+			continue
+		}
+		if f.Path != "" && f.Path != r.URL.Path {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *Server) authorized(r *http.Request, token string) bool {
+	return r.Header.Get("Authorization") == "Bearer "+token
+}
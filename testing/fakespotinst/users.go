@@ -0,0 +1,196 @@
+package fakespotinst
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	programmaticUserPath   = "/setup/shared/ums/programmaticUser"
+	accountUserMappingPath = "/setup/shared/accountUserMapping"
+	userPath               = "/setup/shared/ums/user"
+)
+
+func (s *Server) signIn(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, r, http.StatusBadRequest, "INVALID_JSON", err.Error(), "")
+		return
+	}
+	if body.Email != s.Email || body.Password != s.Password {
+		writeError(w, r, http.StatusUnauthorized, "INVALID_CREDENTIALS", "wrong email or password", "")
+		return
+	}
+
+	writeConsoleItems(w, "auth:signIn", map[string]interface{}{
+		"accessToken": s.ConsoleToken,
+	})
+}
+
+func (s *Server) serveUsers(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == programmaticUserPath && r.Method == http.MethodPost:
+		s.createProgrammaticUser(w, r)
+	case r.URL.Path == accountUserMappingPath && r.Method == http.MethodGet:
+		s.listAccountUserMappings(w, r)
+	case strings.HasPrefix(r.URL.Path, userPath+"/") && r.Method == http.MethodDelete:
+		s.deleteUser(w, r, strings.TrimPrefix(r.URL.Path, userPath+"/"))
+	default:
+		writeError(w, r, http.StatusNotFound, "NOT_FOUND", "no such endpoint", "")
+	}
+}
+
+// validatePermissions checks a mapping against the permission strategy
+// of the user, it returns the offending field
+func validatePermissions(strategy string, m AccountMapping) (string, string) {
+	switch strategy {
+	case "ROLE_BASED":
+		if m.AccountRole < 1 || m.AccountRole > 3 {
+			return "invalid account role", "accountRole"
+		}
+	case "POLICY_BASED":
+		if len(m.PolicyIDs) == 0 {
+			return "at least one policy is required", "policyIds"
+		}
+	default:
+		return "invalid permission strategy", "permissionStrategy"
+	}
+	return "", ""
+}
+
+func (s *Server) createProgrammaticUser(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		AccountRole        int      `json:"accountRole"`
+		Accounts           []string `json:"accounts"`
+		Description        string   `json:"description"`
+		Name               string   `json:"name"`
+		PermissionStrategy string   `json:"permissionStrategy"`
+		PolicyIds          []int    `json:"policyIds"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, r, http.StatusBadRequest, "INVALID_JSON", err.Error(), "")
+		return
+	}
+	if body.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "name is required", "name")
+		return
+	}
+	if len(body.Accounts) == 0 {
+		writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "at least one account is required", "accounts")
+		return
+	}
+
+	mapping := AccountMapping{AccountRole: body.AccountRole, PolicyIDs: body.PolicyIds}
+	if msg, field := validatePermissions(body.PermissionStrategy, mapping); msg != "" {
+		writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", msg, field)
+		return
+	}
+
+	s.mu.Lock()
+	for _, id := range body.Accounts {
+		if _, ok := s.accounts[id]; !ok {
+			s.mu.Unlock()
+			writeError(w, r, http.StatusBadRequest, "ACCOUNT_NOT_FOUND", "account "+id+" not found", "accounts")
+			return
+		}
+	}
+
+	s.nextID++
+	u := &User{
+		ID:                 s.nextID,
+		Name:               body.Name,
+		Description:        body.Description,
+		Token:              fmt.Sprintf("fake-user-token-%d", s.nextID),
+		PermissionStrategy: body.PermissionStrategy,
+		Accounts:           make(map[string]AccountMapping, len(body.Accounts)),
+		visibleAfter:       s.listLag,
+	}
+	for _, id := range body.Accounts {
+		u.Accounts[id] = mapping
+	}
+	s.users[u.ID] = u
+	s.mu.Unlock()
+
+	writeConsoleItems(w, "ums:programmaticUser", map[string]interface{}{
+		"token": u.Token,
+		"type":  "programmatic",
+		"name":  u.Name,
+	})
+}
+
+func (s *Server) listAccountUserMappings(w http.ResponseWriter, r *http.Request) {
+	accountID := r.URL.Query().Get("spotinstAccountId")
+
+	s.mu.Lock()
+	ids := make([]int, 0, len(s.users))
+	for id, u := range s.users {
+		if _, ok := u.Accounts[accountID]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	items := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		u := s.users[id]
+		if u.visibleAfter > 0 {
+			u.visibleAfter--
+			continue
+		}
+		items = append(items, userMappingJSON(u, accountID))
+	}
+	s.mu.Unlock()
+
+	writeConsoleItems(w, "ums:accountUserMapping", items...)
+}
+
+// deleteUser deletes the user from every account, the account ID only
+// has to be one the user is mapped to
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request, rawID string) {
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "invalid user id", "id")
+		return
+	}
+	accountID := r.URL.Query().Get("accountId")
+
+	s.mu.Lock()
+	u, ok := s.users[id]
+	if ok {
+		_, ok = u.Accounts[accountID]
+	}
+	if ok {
+		delete(s.users, id)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "USER_NOT_FOUND", "user "+rawID+" not found", "")
+		return
+	}
+	writeConsoleItems(w, "ums:user")
+}
+
+func userMappingJSON(u *User, accountID string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                 u.ID + 100000,
+		"roleBitMask":        u.Accounts[accountID].AccountRole,
+		"permissionStrategy": u.PermissionStrategy,
+		"coreUser": map[string]interface{}{
+			"id":        u.ID,
+			"email":     fmt.Sprintf("%d@programmatic.example.com", u.ID),
+			"firstName": u.Name,
+			"lastName":  nil,
+			"type":      "programmatic",
+		},
+		"userId":         u.ID,
+		"accountId":      accountID,
+		"organizationId": DefaultOrganizationID,
+	}
+}